@@ -5,8 +5,8 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -38,12 +38,147 @@ var upgrader = websocket.Upgrader{
 }
 
 type debugHandler struct {
-	sp          sentryrpcv2.SentryPool
-	pp          systemrpc.SystemPool
-	ugp         userrpc.UGPool
-	tmpPath     string
-	kubectlBin  string
-	auditLogger *zap.Logger
+	sp              sentryrpcv2.SentryPool
+	pp              systemrpc.SystemPool
+	ugp             userrpc.UGPool
+	tmpPath         string
+	kubectlBin      string
+	auditLogger     *zap.Logger
+	pipelineTimeout time.Duration
+	outputLimit     int64
+	policy          *kube.Policy
+	interactive     []kube.InteractiveRule
+	watchTypes      int
+	recordingDir    string
+	recordInput     bool
+	historyDir      string
+	historySize     int
+	aliasDir        string
+	fanoutWorkers   int
+	limits          *sessionLimits
+	idleTimeout     time.Duration
+	idleWarning     time.Duration
+	maxLifetime     time.Duration
+	sessions        *SessionManager
+	configDir       string
+}
+
+// Option is the type to replace default parameters of the debug handler.
+type Option func(h *debugHandler)
+
+// OptionPipelineTimeout to set the maximum run time of kubectl commands piped to filters.
+func OptionPipelineTimeout(d time.Duration) Option {
+	return func(h *debugHandler) {
+		h.pipelineTimeout = d
+	}
+}
+
+// OptionOutputLimit to set the number of bytes of output shown per kubectl command.
+func OptionOutputLimit(n int64) Option {
+	return func(h *debugHandler) {
+		h.outputLimit = n
+	}
+}
+
+// OptionCommandPolicy to set the policy kubectl commands are checked against.
+func OptionCommandPolicy(p *kube.Policy) Option {
+	return func(h *debugHandler) {
+		h.policy = p
+	}
+}
+
+// OptionInteractiveCommands to set the rules for kubectl commands run attached to a pseudo terminal.
+func OptionInteractiveCommands(rules []kube.InteractiveRule) Option {
+	return func(h *debugHandler) {
+		h.interactive = rules
+	}
+}
+
+// OptionCompletionWatch to keep completions of up to maxTypes resource types
+// per session up to date with informers, 0 disables it.
+func OptionCompletionWatch(maxTypes int) Option {
+	return func(h *debugHandler) {
+		h.watchTypes = maxTypes
+	}
+}
+
+// OptionRecordingDir to record sessions as asciicast files in dir.
+func OptionRecordingDir(dir string) Option {
+	return func(h *debugHandler) {
+		h.recordingDir = dir
+	}
+}
+
+// OptionRecordInput to record the data typed by users in recordings, not
+// only when it was typed.
+func OptionRecordInput(record bool) Option {
+	return func(h *debugHandler) {
+		h.recordInput = record
+	}
+}
+
+// OptionHistory to persist the command history of users per cluster in dir,
+// keeping up to size commands.
+func OptionHistory(dir string, size int) Option {
+	return func(h *debugHandler) {
+		h.historyDir = dir
+		h.historySize = size
+	}
+}
+
+// OptionAliasDir to persist the command aliases of users in dir.
+func OptionAliasDir(dir string) Option {
+	return func(h *debugHandler) {
+		h.aliasDir = dir
+	}
+}
+
+// OptionFanoutWorkers to set the number of clusters fanout commands run on at
+// the same time.
+func OptionFanoutWorkers(n int) Option {
+	return func(h *debugHandler) {
+		h.fanoutWorkers = n
+	}
+}
+
+// OptionSessionLimits to set the maximum number of concurrent sessions per
+// user and in total, zero for no limit.
+func OptionSessionLimits(perUser, total int) Option {
+	return func(h *debugHandler) {
+		h.limits = newSessionLimits(perUser, total)
+	}
+}
+
+// OptionIdleTimeout to close sessions without keystrokes or output for the
+// timeout, the user is warned the given duration before.
+func OptionIdleTimeout(timeout, warning time.Duration) Option {
+	return func(h *debugHandler) {
+		h.idleTimeout = timeout
+		h.idleWarning = warning
+	}
+}
+
+// OptionMaxSessionLifetime to close sessions after running for d.
+func OptionMaxSessionLifetime(d time.Duration) Option {
+	return func(h *debugHandler) {
+		h.maxLifetime = d
+	}
+}
+
+// OptionSessionManager to track the sessions with m, e.g. to list them or
+// end them on shutdown.
+func OptionSessionManager(m *SessionManager) Option {
+	return func(h *debugHandler) {
+		h.sessions = m
+	}
+}
+
+// OptionKubeconfigDir to write the kubeconfigs of sessions to dir, e.g. on a
+// memory backed filesystem, instead of the temp path.
+func OptionKubeconfigDir(dir string) Option {
+	return func(h *debugHandler) {
+		h.configDir = dir
+	}
 }
 
 type reqAuth struct {
@@ -59,7 +194,7 @@ type reqAuth struct {
 	GlobalScope        bool
 }
 
-func (h *debugHandler) getAuth(r *http.Request, ps httprouter.Params) (*reqAuth, error) {
+func getAuth(r *http.Request, ps httprouter.Params) (*reqAuth, error) {
 
 	sd, ok := service.GetSessionDataFromContext(r.Context())
 	if !ok {
@@ -86,7 +221,9 @@ func (h *debugHandler) getKubeConfig(ctx context.Context, auth *reqAuth, cluster
 
 	nCtx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
+	start := time.Now()
 	sc, err := h.sp.NewClient(nCtx)
+	observeRPC("NewClient", start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -114,16 +251,19 @@ func (h *debugHandler) getKubeConfig(ctx context.Context, auth *reqAuth, cluster
 
 	opts.Selector = strings.Join(selector, ",")
 
+	start = time.Now()
 	if isSystemSession {
 		resp, err = sc.GetForClusterSystemSession(nCtx, &sentryrpcv2.GetForClusterRequest{
 			Opts:      &opts,
 			Namespace: nameSpace,
 		})
+		observeRPC("GetForClusterSystemSession", start, err)
 	} else {
 		resp, err = sc.GetForClusterWebSession(nCtx, &sentryrpcv2.GetForClusterRequest{
 			Opts:      &opts,
 			Namespace: nameSpace,
 		})
+		observeRPC("GetForClusterWebSession", start, err)
 	}
 
 	if err != nil {
@@ -134,16 +274,27 @@ func (h *debugHandler) getKubeConfig(ctx context.Context, auth *reqAuth, cluster
 
 }
 
+// setupPromptEnv creates the directories of the session, only accessible by
+// the user of the process, and writes the kubeconfig to the kubeconfig dir.
 func (h *debugHandler) setupPromptEnv(dPath string, kubeConfig []byte) (args []string, err error) {
 	path := fmt.Sprintf("%s/%s", h.tmpPath, dPath)
-	err = os.MkdirAll(path, 0755)
+	// the directory is not reused if it exists, it may have been created by
+	// another user.
+	err = os.Mkdir(path, 0700)
 	if err != nil {
 		return
 	}
 
-	kubeConfigPath := fmt.Sprintf("%s/kubeconfig.yaml", path)
+	configPath := fmt.Sprintf("%s/%s", h.kubeconfigDir(), dPath)
+	if configPath != path {
+		err = os.Mkdir(configPath, 0700)
+		if err != nil {
+			return
+		}
+	}
+	kubeConfigPath := fmt.Sprintf("%s/kubeconfig.yaml", configPath)
 
-	err = ioutil.WriteFile(kubeConfigPath, kubeConfig, 0644)
+	err = writeKubeConfig(kubeConfigPath, kubeConfig)
 	if err != nil {
 		return
 	}
@@ -155,19 +306,96 @@ func (h *debugHandler) setupPromptEnv(dPath string, kubeConfig []byte) (args []s
 }
 
 func (h *debugHandler) teardownPromptEnv(dPath string) {
+	os.RemoveAll(fmt.Sprintf("%s/%s", h.kubeconfigDir(), dPath))
 	os.RemoveAll(fmt.Sprintf("%s/%s", h.tmpPath, dPath))
 }
 
+// kubeconfigDir returns the directory the kubeconfigs of sessions are
+// written to, tmpPath unless configured.
+func (h *debugHandler) kubeconfigDir() string {
+	if h.configDir != "" {
+		return h.configDir
+	}
+	return h.tmpPath
+}
+
+// loadAliases returns the command aliases of the user, they are only kept for
+// the session if they can not be stored.
+func (h *debugHandler) loadAliases(username string) *kube.Aliases {
+	var path string
+	if user := url.PathEscape(username); h.aliasDir != "" && isPathElement(user) {
+		path = filepath.Join(h.aliasDir, user+".json")
+	}
+	aliases, err := kube.LoadAliases(path)
+	if err != nil {
+		_log.Infow("unable to load aliases", "username", username, "error", err)
+		aliases, _ = kube.LoadAliases("")
+	}
+	return aliases
+}
+
+// historyOptions returns the prompt options to load and persist the command
+// history of the user on the cluster.
+func (h *debugHandler) historyOptions(username, clusterName string) []prompt.Option {
+	if h.historyDir == "" {
+		return nil
+	}
+	user, cluster := url.PathEscape(username), url.PathEscape(clusterName)
+	if !isPathElement(user) || !isPathElement(cluster) {
+		_log.Infow("unable to store history", "username", username, "cluster", clusterName)
+		return nil
+	}
+
+	store := kube.NewFileHistoryStore(filepath.Join(h.historyDir, user, cluster), h.historySize)
+	entries, err := store.Load()
+	if err != nil {
+		_log.Infow("unable to load history", "error", err)
+		return nil
+	}
+	return []prompt.Option{
+		prompt.OptionHistory(entries),
+		prompt.OptionHistoryStore(store),
+	}
+}
+
+// keyBindMode returns the key bindings the user selected with the keybind
+// query parameter, the common ones if none or unknown ones are selected.
+func keyBindMode(r *http.Request) prompt.KeyBindMode {
+	switch m := prompt.KeyBindMode(r.URL.Query().Get("keybind")); m {
+	case prompt.EmacsKeyBind, prompt.ViKeyBind:
+		return m
+	default:
+		return prompt.CommonKeyBind
+	}
+}
+
 func (h *debugHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	var decodedCmd string
 
-	auth, err := h.getAuth(r, ps)
+	auth, err := getAuth(r, ps)
 	if err != nil {
 		_log.Infow("unable to get auth", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	release, reason := h.limits.acquire(auth.Username)
+	if release == nil {
+		_log.Infow("rejected session", "username", auth.Username, "reason", reason)
+		http.Error(w, reason, http.StatusTooManyRequests)
+		return
+	}
+	defer release()
+
+	ms, ctx, done, err := h.sessions.start(r.Context(), auth)
+	if err != nil {
+		_log.Infow("rejected session", "username", auth.Username, "reason", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	// the session is only removed from the manager once it is torn down
+	defer done()
+
 	clusterName := ps.ByName("cluster_name")
 
 	nameSpace := sanitizeValue(r.URL.Query().Get("namespace"))
@@ -181,6 +409,22 @@ func (h *debugHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprou
 	}
 	_log.Infow("Handle", "post router", ps, "nameSpace", nameSpace, "command", command, "decoded", decodedCmd)
 
+	rows := r.URL.Query().Get("rows")
+	cols := r.URL.Query().Get("cols")
+
+	rowsUint, err := strconv.ParseUint(rows, 10, 16)
+	if err != nil {
+		_log.Infow("unable to parse rows", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	colsUint, err := strconv.ParseUint(cols, 10, 16)
+	if err != nil {
+		_log.Infow("unable to parse cols", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	kubeConfig, err := h.getKubeConfig(r.Context(), auth, clusterName, nameSpace, false)
 	if err != nil {
 		_log.Infow("unable to get kube config", "error", err)
@@ -192,11 +436,23 @@ func (h *debugHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprou
 
 	args, err := h.setupPromptEnv(dPath, kubeConfig)
 	if err != nil {
+		h.teardownPromptEnv(dPath)
 		_log.Infow("unable to setup prompt env", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	session, err := newPromptSession(h, r, auth, clusterName, dPath, kubeConfig, h.loadAliases(auth.Username))
+	if err != nil {
+		h.teardownPromptEnv(dPath)
+		_log.Infow("unable to create completer", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the kubeconfig is removed and the completer released however the
+	// session ends
+	defer session.close()
+
 	go func() {
 		// prime cache for faster initial response
 		var execArgs []string
@@ -208,32 +464,25 @@ func (h *debugHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprou
 
 		execArgs = append(execArgs, "api-resources")
 
-		_, err = exec.Command(h.kubectlBin, execArgs...).Output()
+		_, err := exec.CommandContext(ctx, h.kubectlBin, execArgs...).Output()
 		if err == nil {
-			cmdExec := exec.Command(h.kubectlBin, execArgs...)
-			err = cmdExec.Run()
+			exec.CommandContext(ctx, h.kubectlBin, execArgs...).Run()
 		}
 	}()
 
-	rows := r.URL.Query().Get("rows")
-	cols := r.URL.Query().Get("cols")
-
-	rowsUint, err := strconv.ParseUint(rows, 10, 16)
-	if err != nil {
-		_log.Infow("unable to parse rows", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	colsUint, err := strconv.ParseUint(cols, 10, 16)
-	if err != nil {
-		_log.Infow("unable to parse cols", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	var recPath string
+	if h.recordingDir != "" {
+		recPath, err = recordingPath(h.recordingDir, auth.ProjectID, clusterName, dPath)
+		if err != nil {
+			_log.Infow("unable to record session", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
-	c, err := kube.NewCompleter(context.Background(), kubeConfig)
+	event, err := h.GetEventForKubectlCommands(r, auth, clusterName)
 	if err != nil {
-		_log.Infow("unable to create completer", "error", err)
+		_log.Infow("unable to get audit for kubectl commands", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -244,53 +493,107 @@ func (h *debugHandler) Handle(w http.ResponseWriter, r *http.Request, ps httprou
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	ctx, cancel := context.WithCancel(r.Context())
+	// unblocks reads of the prompt and of the commands it runs
+	defer conn.Close()
+
+	// the recording is only created for an upgraded connection, so that
+	// failed upgrades leave no empty recordings.
+	var rec *recorder
+	if recPath != "" {
+		rec, err = newRecorder(recPath, uint16(rowsUint), uint16(colsUint), auth.Username, clusterName, h.recordInput)
+		if err != nil {
+			_log.Infow("unable to record session", "error", err)
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "unable to record session"), time.Now().Add(time.Second))
+			return
+		}
+		defer rec.close()
+		session.rec = rec
+	}
 
 	conn.SetCloseHandler(func(code int, text string) error {
 		_log.Infow("client closed websocket")
-		cancel()
-		h.teardownPromptEnv(dPath)
+		ms.stop(endClientClosed)
 		return nil
 	})
 
-	rw := newWSReadWriter(conn)
+	term := kube.NewTerminal(uint16(rowsUint), uint16(colsUint))
+	var parser prompt.ResizableParser
+	rw := newWSReadWriter(conn, rec, func(rows, cols uint16) {
+		term.Resize(rows, cols)
+		parser.SetWinSize(&prompt.WinSize{Row: rows, Col: cols})
+	})
+	parser = prompt.NewIOParser(uint16(rowsUint), uint16(colsUint), rw)
+	defer rw.close()
 
-	event, err := h.GetEventForKubectlCommands(r, auth, clusterName)
-	if err != nil {
-		_log.Infow("unable to get audit for kubectl commands", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	ms.attach(session, rw)
+	go h.watchSession(ctx, ms, rw)
+	go func() {
+		select {
+		case <-rw.disconnected:
+			_log.Infow("websocket disconnected")
+			ms.stop(endDisconnected)
+		case <-ctx.Done():
+		}
+	}()
 
+	promptDone := make(chan struct{})
 	go func() {
+		defer close(promptDone)
 
-		p := prompt.New(
-			kube.NewIOExecutor(rw, uint16(rowsUint), uint16(colsUint), args, event, h.kubectlBin, h.auditLogger),
-			c.Complete,
-			prompt.OptionParser(prompt.NewIOParser(uint16(rowsUint), uint16(colsUint), rw)),
+		opts := []prompt.Option{
+			prompt.OptionParser(parser),
 			prompt.OptionWriter(prompt.NewIOWriter(rw)),
 			prompt.OptionTitle("paralus-prompt: interactive kubernetes client"),
 			prompt.OptionPrefix("kubectl "),
+			prompt.OptionLivePrefix(session.prefix),
 			prompt.OptionPrefixTextColor(prompt.Green),
 			prompt.OptionInputTextColor(prompt.Yellow),
 			prompt.OptionCompletionWordSeparator(completer.FilePathCompletionSeparator),
-			prompt.OptionSwitchKeyBindMode(prompt.CommonKeyBind),
+			prompt.OptionSwitchKeyBindMode(keyBindMode(r)),
+			prompt.OptionConfirmMultilinePaste(),
+		}
+		opts = append(opts, h.historyOptions(auth.Username, clusterName)...)
+
+		p := prompt.New(
+			kube.NewIOExecutor(rw, term, args, event, h.kubectlBin, h.auditLogger,
+				kube.OptionPipelineTimeout(h.pipelineTimeout),
+				kube.OptionOutputLimit(h.outputLimit),
+				kube.OptionPolicy(h.policy),
+				kube.OptionInteractiveRules(h.interactive),
+				kube.OptionAliases(session.aliases),
+				kube.OptionDefaultNamespace(session.namespace),
+				kube.OptionOnNamespaceChange(session.setNamespace),
+				kube.OptionClusterSwitcher(session),
+				kube.OptionClusterFanout(session),
+				kube.OptionFanoutWorkers(h.fanoutWorkers),
+			),
+			session.complete,
+			opts...,
 		)
 		if decodedCmd != "" {
 			p.RunPreset(ctx, decodedCmd)
 		} else {
 			p.Run(ctx)
 		}
+		// the prompt exits on its own, e.g. by exit
+		ms.stop(endExit)
 	}()
 
 	<-ctx.Done()
 	_log.Infow("closing websocket context done")
 
+	// commands run by the prompt are killed with the context, their
+	// kubeconfig is only removed once they exited.
+	conn.Close()
+	select {
+	case <-promptDone:
+	case <-time.After(promptExitTimeout):
+		_log.Infow("prompt did not exit, removing kubeconfig anyway", "session", ms.id)
+	}
 }
 
 // NewDebugHandler returns debug handler
-func NewDebugHandler(sp sentryrpcv2.SentryPool, pp systemrpc.SystemPool, ugp userrpc.UGPool, tmpPath, kubectlBin string, auditLogger *zap.Logger) httprouter.Handle {
+func NewDebugHandler(sp sentryrpcv2.SentryPool, pp systemrpc.SystemPool, ugp userrpc.UGPool, tmpPath, kubectlBin string, auditLogger *zap.Logger, opts ...Option) httprouter.Handle {
 	dh := &debugHandler{
 		sp:          sp,
 		pp:          pp,
@@ -300,6 +603,16 @@ func NewDebugHandler(sp sentryrpcv2.SentryPool, pp systemrpc.SystemPool, ugp use
 		auditLogger: auditLogger,
 	}
 
+	for _, opt := range opts {
+		opt(dh)
+	}
+	if dh.limits == nil {
+		dh.limits = newSessionLimits(0, 0)
+	}
+	if dh.sessions == nil {
+		dh.sessions = NewSessionManager()
+	}
+
 	return dh.Handle
 }
 
@@ -311,14 +624,18 @@ func getUsername() string {
 	return viper.GetString("USER_NAME")
 }
 
-func hasKubeCache(path string) bool {
-	if strings.Contains(path, "kubectlview-") {
-		s := strings.Split(path, "/")
-		if len(s) == 3 {
-			return true
-		}
+// hasKubeCache reports whether path is the directory of a session in root,
+// named by its xid, or a kubectlview- cache directory.
+func hasKubeCache(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || strings.ContainsRune(rel, filepath.Separator) {
+		return false
 	}
-	return false
+	if strings.HasPrefix(rel, "kubectlview-") {
+		return true
+	}
+	_, err = xid.FromString(rel)
+	return err == nil
 }
 
 func isStaleDir(cacheDir string) bool {
@@ -345,10 +662,14 @@ func staleCacheDirCheck(root string) {
 	var staleDir []string
 
 	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if info != nil && info.IsDir() && hasKubeCache(path) && isStaleDir(path) {
+		if info == nil || !info.IsDir() || path == root {
+			return nil
+		}
+		if hasKubeCache(root, path) && isStaleDir(path) {
 			staleDir = append(staleDir, path)
 		}
-		return nil
+		// session directories are only created in root
+		return filepath.SkipDir
 	})
 
 	if err != nil {
@@ -0,0 +1,94 @@
+package debug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	sentryrpcv2 "github.com/paralus/paralus/proto/rpc/sentry"
+)
+
+// readyCheckTimeout is how long the readiness check waits for a connection
+// to sentry.
+const readyCheckTimeout = 2 * time.Second
+
+// HealthHandler serves the liveness and readiness of the server.
+type HealthHandler struct {
+	sp         sentryrpcv2.SentryPool
+	kubectlBin string
+	sessions   *SessionManager
+}
+
+// NewHealthHandler returns handler which is ready once sentry is reachable
+// through sp and kubectlBin can be run, and until sessions are drained.
+func NewHealthHandler(sp sentryrpcv2.SentryPool, kubectlBin string, sessions *SessionManager) *HealthHandler {
+	return &HealthHandler{sp: sp, kubectlBin: kubectlBin, sessions: sessions}
+}
+
+// Healthz answers as long as the server is running.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fmt.Fprintln(w, "ok")
+}
+
+// Readyz writes the result of every readiness check, it fails with 503 if a
+// check failed.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"sessions", h.checkSessions},
+		{"sentry", h.checkSentry},
+		{"kubectl", h.checkKubectl},
+	}
+
+	var b strings.Builder
+	ready := true
+	for _, c := range checks {
+		if err := c.check(r.Context()); err != nil {
+			ready = false
+			fmt.Fprintf(&b, "%s: %s\n", c.name, err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: ok\n", c.name)
+	}
+
+	if !ready {
+		_log.Infow("server is not ready", "checks", b.String())
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(w, b.String())
+}
+
+func (h *HealthHandler) checkSessions(ctx context.Context) error {
+	if h.sessions != nil && h.sessions.Draining() {
+		return errShuttingDown
+	}
+	return nil
+}
+
+func (h *HealthHandler) checkSentry(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+	sc, err := h.sp.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	return sc.Close()
+}
+
+func (h *HealthHandler) checkKubectl(ctx context.Context) error {
+	fi, err := os.Stat(h.kubectlBin)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		return errors.New(h.kubectlBin + " is not an executable file")
+	}
+	return nil
+}
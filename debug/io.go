@@ -1,8 +1,11 @@
 package debug
 
 import (
-	"io"
+	"bytes"
+	"context"
+	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -13,34 +16,180 @@ var (
 	_log = logv2.GetLogger()
 )
 
+const (
+	controlResize = "resize"
+)
+
+// controlMessage is sent by the dashboard out of band of the keystroke data,
+// e.g. {"type":"resize","rows":40,"cols":120} when the terminal panel is resized.
+type controlMessage struct {
+	Type string `json:"type"`
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
+}
+
+// parseControlMessage returns the control message in p, false if p is
+// keystroke data.
+func parseControlMessage(p []byte) (*controlMessage, bool) {
+	if !bytes.HasPrefix(p, []byte(`{"type":`)) {
+		return nil, false
+	}
+	var msg controlMessage
+	if err := json.Unmarshal(p, &msg); err != nil {
+		return nil, false
+	}
+	switch msg.Type {
+	case controlResize:
+		if msg.Rows == 0 || msg.Cols == 0 {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return &msg, true
+}
+
 type wsReadWriter struct {
-	conn *websocket.Conn
-	m    sync.RWMutex
+	conn     *websocket.Conn
+	m        sync.RWMutex
+	rec      *recorder
+	onResize func(rows, cols uint16)
+
+	// in receives the keystroke data read from the websocket by readLoop,
+	// it is closed once reading fails with readErr.
+	in      chan []byte
+	readErr error
+	// pending holds data received from in but not read yet.
+	rm      sync.Mutex
+	pending []byte
+	done    chan struct{}
+	once    sync.Once
+	// disconnected is closed once reading the websocket failed.
+	disconnected chan struct{}
+
+	// active is the time of the last keystroke or output in unix nanoseconds.
+	active atomic.Int64
 }
 
-func newWSReadWriter(conn *websocket.Conn) io.ReadWriter {
-	ws := &wsReadWriter{conn: conn}
+// newWSReadWriter returns ReadWriter for the terminal attached to conn, the
+// terminal is recorded by rec unless it is nil.
+func newWSReadWriter(conn *websocket.Conn, rec *recorder, onResize func(rows, cols uint16)) *wsReadWriter {
+	ws := &wsReadWriter{
+		conn:     conn,
+		rec:      rec,
+		onResize: onResize,
+		in:       make(chan []byte),
+		done:     make(chan struct{}),
+
+		disconnected: make(chan struct{}),
+	}
+	ws.active.Store(time.Now().UnixNano())
 	go ws.keepAlive(time.Second * 60)
+	go ws.readLoop()
 	return ws
 }
 
-func (rw *wsReadWriter) Read(p []byte) (n int, err error) {
-
+// readLoop is the only reader of the websocket, so that reads can be
+// cancelled without losing keystrokes, see ReadContext.
+func (rw *wsReadWriter) readLoop() {
+	defer close(rw.disconnected)
+	defer close(rw.in)
 	for {
-		var reader io.Reader
-		_, reader, err = rw.conn.NextReader()
+		_, p, err := rw.conn.ReadMessage()
 		if err != nil {
 			_log.Errorw("unable to get next reader", "error", err)
-			break
+			rw.readErr = err
+			return
 		}
 		rw.conn.SetReadDeadline(time.Now().Add(time.Minute * 20))
-		return reader.Read(p)
+		if msg, ok := parseControlMessage(p); ok {
+			rw.handleControlMessage(msg)
+			continue
+		}
+		if len(p) == 0 {
+			continue
+		}
+		rw.rec.input(p)
+		rw.active.Store(time.Now().UnixNano())
+		select {
+		case rw.in <- p:
+		case <-rw.done:
+			return
+		}
+	}
+}
+
+func (rw *wsReadWriter) Read(p []byte) (n int, err error) {
+	return rw.ReadContext(context.Background(), p)
+}
+
+// ReadContext reads keystroke data like Read, unless ctx is done first.
+func (rw *wsReadWriter) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	rw.rm.Lock()
+	defer rw.rm.Unlock()
+
+	if len(rw.pending) == 0 {
+		select {
+		case data, ok := <-rw.in:
+			if !ok {
+				return 0, rw.readErr
+			}
+			rw.pending = data
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	n = copy(p, rw.pending)
+	rw.pending = rw.pending[n:]
+	return n, nil
+}
+
+// close stops reading the websocket.
+func (rw *wsReadWriter) close() {
+	rw.once.Do(func() { close(rw.done) })
+}
+
+func (rw *wsReadWriter) handleControlMessage(msg *controlMessage) {
+	switch msg.Type {
+	case controlResize:
+		_log.Debugw("resizing terminal", "rows", msg.Rows, "cols", msg.Cols)
+		rw.rec.resize(msg.Rows, msg.Cols)
+		if rw.onResize != nil {
+			rw.onResize(msg.Rows, msg.Cols)
+		}
 	}
-	return 0, err
 }
 
 func (rw *wsReadWriter) Write(p []byte) (n int, err error) {
+	rw.active.Store(time.Now().UnixNano())
+	return rw.write(p)
+}
 
+// lastActive returns when the user typed or a command wrote output last.
+func (rw *wsReadWriter) lastActive() time.Time {
+	return time.Unix(0, rw.active.Load())
+}
+
+// notify writes the message on its own line, without counting it as activity
+// of the session.
+func (rw *wsReadWriter) notify(msg string) {
+	if _, err := rw.write([]byte("\r\n" + msg + "\r\n")); err != nil {
+		_log.Infow("unable to notify user", "error", err)
+	}
+}
+
+// closeConn closes the websocket with the close code and reason.
+func (rw *wsReadWriter) closeConn(code int, reason string) {
+	rw.m.Lock()
+	defer rw.m.Unlock()
+	err := rw.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	if err != nil {
+		_log.Infow("unable to send close message", "error", err)
+	}
+	rw.conn.Close()
+}
+
+func (rw *wsReadWriter) write(p []byte) (n int, err error) {
 	rw.m.Lock()
 	defer rw.m.Unlock()
 
@@ -53,45 +202,45 @@ func (rw *wsReadWriter) Write(p []byte) (n int, err error) {
 	rw.conn.SetReadDeadline(time.Now().Add(time.Minute * 20))
 	_log.Debugw("writing", "message", string(p))
 
-	return writer.Write(p)
+	n, err = writer.Write(p)
+	rw.rec.output(p[:n])
+	return n, err
 
 }
 
+// keepAlive pings the client until the websocket is closed, it is closed
+// if the client did not answer for timeout.
 func (rw *wsReadWriter) keepAlive(timeout time.Duration) {
-	lastResponse := time.Now()
+	var lastResponse atomic.Int64
+	lastResponse.Store(time.Now().UnixNano())
 	rw.conn.SetPongHandler(func(msg string) error {
-		lastResponse = time.Now()
+		lastResponse.Store(time.Now().UnixNano())
 		return nil
 	})
 
-	go func() {
-		errChan := make(chan error)
-		ticker := time.NewTicker(timeout / 2)
-		defer ticker.Stop()
-
-	keepAliveLoop:
-		for ; true; <-ticker.C {
-
-			go func() {
-				rw.m.Lock()
-				defer rw.m.Unlock()
-				err := rw.conn.WriteMessage(websocket.PingMessage, []byte{})
-				if err != nil {
-					errChan <- err
-
-				}
-			}()
-
-			select {
-			case <-errChan:
-				break keepAliveLoop
-			default:
-				if time.Now().Sub(lastResponse) > timeout {
-					rw.conn.Close()
-					break keepAliveLoop
-				}
-			}
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		rw.m.Lock()
+		err := rw.conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(timeout/2))
+		rw.m.Unlock()
+		if err != nil {
+			_log.Infow("unable to ping websocket", "error", err)
+			return
 		}
 
-	}()
+		select {
+		case <-rw.done:
+			return
+		case <-rw.disconnected:
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, lastResponse.Load())) > timeout {
+			_log.Infow("websocket did not answer ping, closing")
+			rw.conn.Close()
+			return
+		}
+	}
 }
@@ -0,0 +1,104 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// sessionCheckInterval is how often the idle time and lifetime of a session
+// are checked.
+const sessionCheckInterval = time.Second
+
+// sessionLimits counts the sessions running per user and in total.
+type sessionLimits struct {
+	m       sync.Mutex
+	perUser int
+	total   int
+	users   map[string]int
+	running int
+}
+
+func newSessionLimits(perUser, total int) *sessionLimits {
+	return &sessionLimits{perUser: perUser, total: total, users: map[string]int{}}
+}
+
+// acquire counts a session of the user, it returns the reason if a limit is
+// reached. release is called once the session ended.
+func (l *sessionLimits) acquire(user string) (release func(), reason string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.total > 0 && l.running >= l.total {
+		return nil, fmt.Sprintf("too many sessions, the limit of %d sessions is reached", l.total)
+	}
+	if l.perUser > 0 && l.users[user] >= l.perUser {
+		return nil, fmt.Sprintf("too many sessions of user %s, the limit of %d sessions per user is reached", user, l.perUser)
+	}
+	l.running++
+	l.users[user]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.m.Lock()
+			defer l.m.Unlock()
+			l.running--
+			if l.users[user]--; l.users[user] <= 0 {
+				delete(l.users, user)
+			}
+		})
+	}, ""
+}
+
+// watchSession ends the session once it was idle for the idle timeout or
+// ran for the maximum lifetime, the user is warned in the terminal before.
+func (h *debugHandler) watchSession(ctx context.Context, ms *managedSession, rw *wsReadWriter) {
+	if h.idleTimeout <= 0 && h.maxLifetime <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(sessionCheckInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	idleWarned, lifetimeWarned := false, false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if h.maxLifetime > 0 {
+			left := h.maxLifetime - time.Since(start)
+			if left <= 0 {
+				ms.end(websocket.CloseNormalClosure, endLifetime, fmt.Sprintf("session reached the maximum lifetime of %s", h.maxLifetime))
+				return
+			}
+			if left <= h.idleWarning && !lifetimeWarned {
+				lifetimeWarned = true
+				rw.notify(fmt.Sprintf("session reaches the maximum lifetime of %s and is closed in %s", h.maxLifetime, left.Round(time.Second)))
+			}
+		}
+
+		if h.idleTimeout > 0 {
+			idle := time.Since(rw.lastActive())
+			switch {
+			case idle >= h.idleTimeout:
+				ms.end(websocket.CloseNormalClosure, endIdle, fmt.Sprintf("session was idle for %s", h.idleTimeout))
+				return
+			case idle >= h.idleTimeout-h.idleWarning:
+				if !idleWarned {
+					idleWarned = true
+					rw.notify(fmt.Sprintf("session is idle and is closed in %s, press any key to keep it open", (h.idleTimeout - idle).Round(time.Second)))
+				}
+			default:
+				idleWarned = false
+			}
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/xid"
+)
+
+// promptExitTimeout is how long a session waits for the prompt to exit once
+// the session ended, before its kubeconfig is removed anyway.
+const promptExitTimeout = 10 * time.Second
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// drainBanners are the times left of the grace period at which the users are
+// reminded that their session is closed.
+var drainBanners = []time.Duration{5 * time.Minute, 2 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second}
+
+// SessionInfo describes a live prompt session.
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	Project    string    `json:"project"`
+	Cluster    string    `json:"cluster"`
+	Namespace  string    `json:"namespace"`
+	Started    time.Time `json:"started"`
+	LastActive time.Time `json:"lastActive,omitempty"`
+}
+
+// SessionManager tracks the live prompt sessions, so that they can be listed
+// by operators and ended on shutdown.
+type SessionManager struct {
+	m        sync.Mutex
+	sessions map[string]*managedSession
+	closing  bool
+	wg       sync.WaitGroup
+}
+
+// managedSession is a session tracked by the manager, rw is set once the
+// websocket is connected.
+type managedSession struct {
+	id       string
+	username string
+	project  string
+	started  time.Time
+	cancel   context.CancelFunc
+
+	m       sync.Mutex
+	session *promptSession
+	rw      *wsReadWriter
+	// reason is why the session ended, the first reason is kept.
+	reason string
+}
+
+// NewSessionManager returns manager without sessions.
+func NewSessionManager() *SessionManager {
+	return &SessionManager{sessions: map[string]*managedSession{}}
+}
+
+// start tracks a new session of the user, the returned context is cancelled
+// when the session is ended by the manager. done is called once the session
+// is torn down.
+func (m *SessionManager) start(ctx context.Context, auth *reqAuth) (s *managedSession, sctx context.Context, done func(), err error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if m.closing {
+		return nil, nil, nil, errShuttingDown
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	s = &managedSession{
+		id:       xid.New().String(),
+		username: auth.Username,
+		project:  auth.ProjectID,
+		started:  time.Now(),
+		cancel:   cancel,
+	}
+	m.sessions[s.id] = s
+	m.wg.Add(1)
+	sessionsStarted.Inc()
+	sessionsActive.Inc()
+
+	var once sync.Once
+	return s, sctx, func() {
+		once.Do(func() {
+			s.stop(endError)
+			s.m.Lock()
+			reason := s.reason
+			s.m.Unlock()
+			sessionsEnded.WithLabelValues(reason).Inc()
+			sessionsActive.Dec()
+
+			m.m.Lock()
+			delete(m.sessions, s.id)
+			m.m.Unlock()
+			m.wg.Done()
+		})
+	}, nil
+}
+
+// attach sets the prompt session and terminal of the session.
+func (s *managedSession) attach(session *promptSession, rw *wsReadWriter) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.session, s.rw = session, rw
+}
+
+// stop cancels the session, reason is one of the reasons of the sessions
+// ended metric.
+func (s *managedSession) stop(reason string) {
+	s.m.Lock()
+	if s.reason == "" {
+		s.reason = reason
+	}
+	s.m.Unlock()
+	s.cancel()
+}
+
+// end tells the user why the session ends and stops it.
+func (s *managedSession) end(code int, reason, message string) {
+	s.m.Lock()
+	rw := s.rw
+	s.m.Unlock()
+	if rw != nil {
+		rw.notify(message + ", closing")
+		rw.closeConn(code, message)
+	}
+	s.stop(reason)
+}
+
+func (s *managedSession) info() SessionInfo {
+	info := SessionInfo{
+		ID:       s.id,
+		Username: s.username,
+		Project:  s.project,
+		Started:  s.started,
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.session != nil {
+		info.Cluster, info.Namespace = s.session.target()
+	}
+	if s.rw != nil {
+		info.LastActive = s.rw.lastActive()
+	}
+	return info
+}
+
+// List returns the live sessions, oldest first.
+func (m *SessionManager) List() []SessionInfo {
+	sessions := m.live()
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, s.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Started.Before(infos[j].Started)
+	})
+	return infos
+}
+
+// Draining returns true once the manager rejects new sessions.
+func (m *SessionManager) Draining() bool {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return m.closing
+}
+
+// Drain rejects new sessions and gives the live ones the grace period to
+// finish, their users are told in the terminal how long is left. Sessions
+// still running after the grace period are ended like by Shutdown.
+func (m *SessionManager) Drain(ctx context.Context, grace time.Duration) error {
+	m.m.Lock()
+	m.closing = true
+	m.m.Unlock()
+
+	done := m.wait()
+	deadline := time.Now().Add(grace)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	_log.Infow("draining sessions", "sessions", len(m.live()), "grace", grace)
+	next := -1
+	for {
+		left := time.Until(deadline)
+		if left <= 0 {
+			break
+		}
+		if next < 0 || (next < len(drainBanners) && left <= drainBanners[next]) {
+			m.notifyAll(fmt.Sprintf("*** server is shutting down, this session is closed in %s ***", left.Round(time.Second)))
+			next = 0
+			for next < len(drainBanners) && drainBanners[next] >= left {
+				next++
+			}
+		}
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return m.Shutdown(ctx)
+}
+
+// Shutdown rejects new sessions, ends the live ones and waits until they
+// are torn down or ctx is done.
+func (m *SessionManager) Shutdown(ctx context.Context) error {
+	m.m.Lock()
+	m.closing = true
+	m.m.Unlock()
+
+	sessions := m.live()
+	_log.Infow("ending sessions", "sessions", len(sessions))
+	for _, s := range sessions {
+		s.end(websocket.CloseGoingAway, endShutdown, errShuttingDown.Error())
+	}
+
+	select {
+	case <-m.wait():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// live returns the sessions not torn down yet.
+func (m *SessionManager) live() []*managedSession {
+	m.m.Lock()
+	defer m.m.Unlock()
+	sessions := make([]*managedSession, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// notifyAll writes msg to the terminals of the live sessions.
+func (m *SessionManager) notifyAll(msg string) {
+	for _, s := range m.live() {
+		s.m.Lock()
+		rw := s.rw
+		s.m.Unlock()
+		if rw != nil {
+			rw.notify(msg)
+		}
+	}
+}
+
+// wait returns channel which is closed once all sessions are torn down.
+func (m *SessionManager) wait() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// ListHandler writes the live sessions as json.
+func (m *SessionManager) ListHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.List()); err != nil {
+		_log.Infow("unable to write sessions", "error", err)
+	}
+}
@@ -0,0 +1,61 @@
+package debug
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons of sessions ending in the sessions ended metric.
+const (
+	endClientClosed = "client_closed"
+	endDisconnected = "disconnected"
+	endExit         = "exit"
+	endIdle         = "idle_timeout"
+	endLifetime     = "max_lifetime"
+	endShutdown     = "shutdown"
+	endError        = "error"
+)
+
+var (
+	sessionsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "prompt",
+		Name:      "sessions_active",
+		Help:      "Prompt sessions running.",
+	})
+	sessionsStarted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "prompt",
+		Name:      "sessions_started_total",
+		Help:      "Prompt sessions started.",
+	})
+	sessionsEnded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "prompt",
+		Name:      "sessions_ended_total",
+		Help:      "Prompt sessions ended by reason.",
+	}, []string{"reason"})
+	sentryRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "prompt",
+		Name:      "sentry_rpc_duration_seconds",
+		Help:      "Latency of sentry RPCs by method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "status"})
+)
+
+// RegisterMetrics registers the metrics of sessions and sentry RPCs with r.
+func RegisterMetrics(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{sessionsActive, sessionsStarted, sessionsEnded, sentryRPCDuration} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeRPC records a sentry RPC started at start which failed with err.
+func observeRPC(method string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	sentryRPCDuration.WithLabelValues(method, status).Observe(time.Since(start).Seconds())
+}
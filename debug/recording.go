@@ -0,0 +1,352 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const recordingExt = ".cast"
+
+// asciicastHeader is the first line of an asciicast v2 recording,
+// see https://docs.asciinema.org/manual/asciicast/v2/
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// recorder writes the terminal output and input of a session as asciicast v2
+// recording. A nil recorder records nothing.
+type recorder struct {
+	m      sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	start  time.Time
+	closed bool
+	// withInput records the data of input events, otherwise only their
+	// time is recorded.
+	withInput bool
+	// pending holds incomplete utf-8 sequences per event type, as the
+	// events of a recording are json strings.
+	pending map[string][]byte
+}
+
+func newRecorder(path string, rows, cols uint16, username, clusterName string, withInput bool) (*recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &recorder{
+		f:         f,
+		w:         bufio.NewWriter(f),
+		start:     time.Now(),
+		withInput: withInput,
+		pending:   map[string][]byte{},
+	}
+	header, _ := json.Marshal(asciicastHeader{
+		Version:   2,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: r.start.Unix(),
+		Title:     fmt.Sprintf("%s@%s", username, clusterName),
+		Env:       map[string]string{"TERM": "xterm-256color", "USER": username},
+	})
+	r.w.Write(header)
+	r.w.WriteByte('\n')
+	if err := r.w.Flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// output records data written to the terminal.
+func (r *recorder) output(p []byte) {
+	r.event("o", p)
+}
+
+// input records data typed by the user, or only when it was typed unless
+// the recorder records input. Input includes passwords typed at prompts
+// without echo, which the output never shows.
+func (r *recorder) input(p []byte) {
+	if r != nil && !r.withInput {
+		r.m.Lock()
+		defer r.m.Unlock()
+		if !r.closed {
+			r.writeLocked("i", "")
+		}
+		return
+	}
+	r.event("i", p)
+}
+
+// resize records a new terminal size.
+func (r *recorder) resize(rows, cols uint16) {
+	r.event("r", []byte(fmt.Sprintf("%dx%d", cols, rows)))
+}
+
+// marker records a marker with the label, e.g. the cluster use-cluster
+// switched to.
+func (r *recorder) marker(label string) {
+	r.event("m", []byte(label))
+}
+
+func (r *recorder) event(typ string, p []byte) {
+	if r == nil {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.closed {
+		return
+	}
+
+	data := append(r.pending[typ], p...)
+	// keep an incomplete utf-8 sequence at the end for the next event
+	n := len(data)
+	for i := 1; i <= utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				n = len(data) - i
+			}
+			break
+		}
+	}
+	r.pending[typ] = append([]byte(nil), data[n:]...)
+	if n == 0 {
+		return
+	}
+	r.writeLocked(typ, string(data[:n]))
+}
+
+func (r *recorder) writeLocked(typ, data string) {
+	line, err := json.Marshal([]interface{}{time.Since(r.start).Seconds(), typ, data})
+	if err != nil {
+		return
+	}
+	r.w.Write(line)
+	r.w.WriteByte('\n')
+	if err := r.w.Flush(); err != nil {
+		_log.Infow("unable to write recording", "file", r.f.Name(), "error", err)
+	}
+}
+
+func (r *recorder) close() {
+	if r == nil {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.w.Flush()
+	r.f.Close()
+}
+
+// isPathElement reports whether s can be used as a single element of a path.
+func isPathElement(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
+
+// recordingPath returns the path of the recording of a session, recordings
+// are stored per project and cluster.
+func recordingPath(dir, project, clusterName, id string) (string, error) {
+	for _, s := range []string{project, clusterName, id} {
+		if !isPathElement(s) {
+			return "", fmt.Errorf("invalid recording path element %q", s)
+		}
+	}
+	return filepath.Join(dir, project, clusterName, id+recordingExt), nil
+}
+
+// Recording describes a recorded session.
+type Recording struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  float64   `json:"duration"`
+	Size      int64     `json:"size"`
+}
+
+// RecordingHandler serves the session recordings of a project and cluster.
+// Recordings include the input of a session, so users only get their own
+// recordings, members of the auditor groups those of all users.
+type RecordingHandler struct {
+	dir           string
+	auditorGroups []string
+}
+
+// NewRecordingHandler returns handler for the recordings stored in dir.
+func NewRecordingHandler(dir string, auditorGroups []string) *RecordingHandler {
+	return &RecordingHandler{dir: dir, auditorGroups: auditorGroups}
+}
+
+func (h *RecordingHandler) clusterDir(r *http.Request, ps httprouter.Params) (*reqAuth, string, error) {
+	auth, err := getAuth(r, ps)
+	if err != nil {
+		return nil, "", err
+	}
+	path, err := recordingPath(h.dir, auth.ProjectID, ps.ByName("cluster_name"), "recording")
+	if err != nil {
+		return nil, "", err
+	}
+	return auth, filepath.Dir(path), nil
+}
+
+// canRead returns whether the user may read the recording.
+func (h *RecordingHandler) canRead(auth *reqAuth, rec *Recording) bool {
+	if rec.Username != "" && rec.Username == auth.Username {
+		return true
+	}
+	for _, g := range auth.Groups {
+		if slices.Contains(h.auditorGroups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// List writes the recordings of the cluster as json, latest first.
+func (h *RecordingHandler) List(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	auth, dir, err := h.clusterDir(r, ps)
+	if err != nil {
+		_log.Infow("unable to get recordings", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		_log.Infow("unable to list recordings", "dir", dir, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	recordings := []Recording{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), recordingExt) {
+			continue
+		}
+		rec, err := readRecording(filepath.Join(dir, e.Name()))
+		if err != nil {
+			_log.Infow("unable to read recording", "file", e.Name(), "error", err)
+			continue
+		}
+		if !h.canRead(auth, rec) {
+			continue
+		}
+		recordings = append(recordings, *rec)
+	}
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].StartedAt.After(recordings[j].StartedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recordings)
+}
+
+// Download writes the recording as asciicast file.
+func (h *RecordingHandler) Download(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	auth, dir, err := h.clusterDir(r, ps)
+	if err != nil {
+		_log.Infow("unable to get recording", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := ps.ByName("recording_id")
+	if !isPathElement(id) {
+		http.Error(w, "invalid recording id", http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(dir, id+recordingExt))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		_log.Infow("unable to open recording", "id", id, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	rec, info, err := recordingHeader(f)
+	if err != nil {
+		_log.Infow("unable to read recording", "id", id, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !h.canRead(auth, rec) {
+		_log.Infow("recording access denied", "id", id, "username", auth.Username)
+		http.Error(w, "recording of another user", http.StatusForbidden)
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+recordingExt))
+	http.ServeContent(w, r, id+recordingExt, info.ModTime(), f)
+}
+
+// readRecording reads the description of a recording from its header.
+func readRecording(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rec, _, err := recordingHeader(f)
+	return rec, err
+}
+
+// recordingHeader reads the description of the recording in f.
+func recordingHeader(f *os.File) (*Recording, os.FileInfo, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return nil, nil, err
+	}
+	var header asciicastHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, nil, err
+	}
+
+	started := time.Unix(header.Timestamp, 0)
+	return &Recording{
+		ID:        strings.TrimSuffix(filepath.Base(f.Name()), recordingExt),
+		Username:  header.Env["USER"],
+		StartedAt: started,
+		Duration:  info.ModTime().Sub(started).Seconds(),
+		Size:      info.Size(),
+	}, info, nil
+}
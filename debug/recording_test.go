@@ -0,0 +1,161 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/paralus/paralus/pkg/common"
+	commonv3 "github.com/paralus/paralus/proto/types/commonpb/v3"
+)
+
+func newRecordingRequest(username string, groups ...string) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	return r.WithContext(context.WithValue(r.Context(), common.SessionDataKey, &commonv3.SessionData{
+		Username: username,
+		Groups:   groups,
+		Project: &commonv3.ProjectData{
+			List: []*commonv3.ProjectRole{{ProjectId: "p1", Project: "default"}},
+		},
+	}))
+}
+
+func record(t *testing.T, dir, id, username string) {
+	t.Helper()
+	path, err := recordingPath(dir, "p1", "c1", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := newRecorder(path, 24, 80, username, "c1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.input([]byte("secret\r"))
+	rec.close()
+}
+
+func TestRecordingHandler(t *testing.T) {
+	dir := t.TempDir()
+	record(t, dir, "alice-1", "alice")
+	record(t, dir, "bob-1", "bob")
+	h := NewRecordingHandler(dir, []string{"auditors"})
+
+	tests := []struct {
+		name     string
+		r        *http.Request
+		list     []string
+		download int
+	}{
+		{
+			name:     "owner",
+			r:        newRecordingRequest("alice"),
+			list:     []string{"alice-1"},
+			download: http.StatusForbidden,
+		},
+		{
+			name:     "other user",
+			r:        newRecordingRequest("bob", "developers"),
+			list:     []string{"bob-1"},
+			download: http.StatusOK,
+		},
+		{
+			name:     "auditor",
+			r:        newRecordingRequest("carol", "auditors"),
+			list:     []string{"alice-1", "bob-1"},
+			download: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := httprouter.Params{{Key: "cluster_name", Value: "c1"}}
+			w := httptest.NewRecorder()
+			h.List(w, tt.r, ps)
+			var recordings []Recording
+			if err := json.NewDecoder(w.Body).Decode(&recordings); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]bool{}
+			for _, rec := range recordings {
+				got[rec.ID] = true
+			}
+			if len(got) != len(tt.list) {
+				t.Errorf("listed %v, want %v", recordings, tt.list)
+			}
+			for _, id := range tt.list {
+				if !got[id] {
+					t.Errorf("%s is not listed", id)
+				}
+			}
+
+			// the recording of bob
+			w = httptest.NewRecorder()
+			h.Download(w, tt.r, append(ps, httprouter.Param{Key: "recording_id", Value: "bob-1"}))
+			if w.Code != tt.download {
+				t.Errorf("download status = %d, want %d", w.Code, tt.download)
+			}
+		})
+	}
+}
+
+func TestRecorderMarker(t *testing.T) {
+	path, err := recordingPath(t.TempDir(), "p1", "c1", "alice-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := newRecorder(path, 24, 80, "alice", "c1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.marker("cluster_to=c2")
+	rec.close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	var event []interface{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if len(event) != 3 || event[1] != "m" || event[2] != "cluster_to=c2" {
+		t.Errorf("should be a marker event, got %v", event)
+	}
+}
+
+func TestRecorderInput(t *testing.T) {
+	for _, withInput := range []bool{false, true} {
+		path, err := recordingPath(t.TempDir(), "p1", "c1", "alice-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := newRecorder(path, 24, 80, "alice", "c1", withInput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec.input([]byte("secret\r"))
+		rec.close()
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		var event []interface{}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &event); err != nil {
+			t.Fatal(err)
+		}
+		expected := ""
+		if withInput {
+			expected = "secret\r"
+		}
+		if len(event) != 3 || event[1] != "i" || event[2] != expected {
+			t.Errorf("with input %t: should be input event %q, got %v", withInput, expected, event)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/paralus/prompt/pkg/kube"
+	"github.com/paralus/prompt/pkg/prompt"
+	"github.com/rs/xid"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// clusterListInterval is how long the clusters of a user are cached for
+// completion.
+const clusterListInterval = time.Minute
+
+// promptSession is the cluster and namespace a prompt session targets, which
+// builtin commands of the prompt change, e.g. use-cluster.
+type promptSession struct {
+	h       *debugHandler
+	r       *http.Request
+	auth    *reqAuth
+	aliases *kube.Aliases
+	// rec records the session, it and the history stay with the initial
+	// cluster when use-cluster switches clusters.
+	rec *recorder
+
+	m         sync.Mutex
+	cluster   string
+	namespace string
+	// dPath is the directory of the kubeconfig of the cluster in tmpPath
+	dPath     string
+	completer *kube.Completer
+	closed    bool
+
+	clusters   []string
+	clustersAt time.Time
+	listing    bool
+}
+
+var (
+	_ kube.ClusterSwitcher = &promptSession{}
+	_ kube.ClusterFanout   = &promptSession{}
+)
+
+func newPromptSession(h *debugHandler, r *http.Request, auth *reqAuth, clusterName, dPath string, kubeConfig []byte, aliases *kube.Aliases) (*promptSession, error) {
+	s := &promptSession{
+		h:       h,
+		r:       r,
+		auth:    auth,
+		aliases: aliases,
+		cluster: clusterName,
+		dPath:   dPath,
+	}
+	c, err := s.newCompleter(clusterName, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	s.completer = c
+	s.namespace = c.Namespace()
+	return s, nil
+}
+
+func (s *promptSession) newCompleter(clusterName string, kubeConfig []byte) (*kube.Completer, error) {
+	return kube.NewCompleter(context.Background(), kubeConfig,
+		kube.OptionCluster(clusterName),
+		kube.OptionWatchCache(s.h.watchTypes),
+		kube.OptionCompleteAliases(s.aliases),
+		kube.OptionClusterNames(s.cachedClusters),
+	)
+}
+
+// prefix returns the prompt prefix showing the cluster and namespace.
+func (s *promptSession) prefix() (string, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return fmt.Sprintf("kubectl [%s/%s] ", s.cluster, s.namespace), true
+}
+
+// complete completes the input with the completer of the current cluster.
+func (s *promptSession) complete(d prompt.Document) []prompt.Suggest {
+	s.m.Lock()
+	c := s.completer
+	s.m.Unlock()
+	return c.Complete(d)
+}
+
+// setNamespace changes the namespace of the session, e.g. by use-namespace.
+func (s *promptSession) setNamespace(ns string) {
+	s.m.Lock()
+	s.namespace = ns
+	c := s.completer
+	s.m.Unlock()
+	c.SetNamespace(ns)
+}
+
+// Cluster returns the name of the cluster of the session.
+func (s *promptSession) Cluster() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.cluster
+}
+
+// Clusters returns the names of the clusters in the kubeconfig of all
+// clusters the user can access.
+func (s *promptSession) Clusters(ctx context.Context) ([]string, error) {
+	kubeConfig, err := s.h.getKubeConfig(ctx, s.auth, "all", "", false)
+	if err != nil {
+		return nil, err
+	}
+	config, err := clientcmd.Load(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(config.Clusters))
+	for name := range config.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s.m.Lock()
+	s.clusters, s.clustersAt = names, time.Now()
+	s.m.Unlock()
+	return names, nil
+}
+
+// cachedClusters returns the clusters listed last, they are listed again in
+// the background once older than clusterListInterval.
+func (s *promptSession) cachedClusters() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.listing && time.Since(s.clustersAt) > clusterListInterval {
+		s.listing = true
+		go func() {
+			if _, err := s.Clusters(context.Background()); err != nil {
+				_log.Infow("unable to list clusters", "error", err)
+			}
+			s.m.Lock()
+			s.listing = false
+			s.m.Unlock()
+		}()
+	}
+	return s.clusters
+}
+
+// UseCluster fetches the kubeconfig of the cluster and replaces the completer
+// and kubeconfig of the session.
+func (s *promptSession) UseCluster(ctx context.Context, name string) (*kube.ClusterSession, error) {
+	names, err := s.Clusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, n := range names {
+		found = found || n == name
+	}
+	if !found {
+		return nil, fmt.Errorf("cluster %s not found", name)
+	}
+
+	kubeConfig, err := s.h.getKubeConfig(ctx, s.auth, name, "", false)
+	if err != nil {
+		return nil, err
+	}
+	dPath := xid.New().String()
+	args, err := s.h.setupPromptEnv(dPath, kubeConfig)
+	if err != nil {
+		s.h.teardownPromptEnv(dPath)
+		return nil, err
+	}
+	c, err := s.newCompleter(name, kubeConfig)
+	if err != nil {
+		s.h.teardownPromptEnv(dPath)
+		return nil, err
+	}
+	event, err := s.h.GetEventForKubectlCommands(s.r, s.auth, name)
+	if err != nil {
+		c.Close()
+		s.h.teardownPromptEnv(dPath)
+		return nil, err
+	}
+
+	s.m.Lock()
+	if s.closed {
+		s.m.Unlock()
+		c.Close()
+		s.h.teardownPromptEnv(dPath)
+		return nil, fmt.Errorf("session closed")
+	}
+	old, oldPath := s.completer, s.dPath
+	s.cluster, s.namespace, s.dPath, s.completer = name, c.Namespace(), dPath, c
+	s.m.Unlock()
+
+	old.Close()
+	s.h.teardownPromptEnv(oldPath)
+	s.rec.marker("cluster_to=" + name)
+	return &kube.ClusterSession{Args: args, Event: event, Namespace: c.Namespace()}, nil
+}
+
+// ClusterArgs writes the kubeconfig of the cluster for a command run by
+// fanout, release removes it again.
+func (s *promptSession) ClusterArgs(ctx context.Context, name string) ([]string, func(), error) {
+	kubeConfig, err := s.h.getKubeConfig(ctx, s.auth, name, "", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	dPath := xid.New().String()
+	release := func() {
+		s.h.teardownPromptEnv(dPath)
+	}
+	args, err := s.h.setupPromptEnv(dPath, kubeConfig)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return args, release, nil
+}
+
+// target returns the cluster and namespace of the session.
+func (s *promptSession) target() (cluster, namespace string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.cluster, s.namespace
+}
+
+// close releases the completer and removes the kubeconfig, the session must
+// not be used afterwards.
+func (s *promptSession) close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.completer.Close()
+	s.h.teardownPromptEnv(s.dPath)
+}
@@ -0,0 +1,57 @@
+package debug
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CheckTempPath creates path if it does not exist and checks that other
+// users can not replace the session directories in it, which are only
+// accessible by the user of the process. Unless path is on a memory backed
+// filesystem it fails if requireMemory is set.
+func CheckTempPath(path string, requireMemory bool) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return fmt.Errorf("unable to create %s: %w", path, err)
+	}
+	info, err := os.Lstat(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	switch mode := info.Mode(); {
+	case mode&os.ModeSymlink != 0:
+		return fmt.Errorf("%s is a symlink", path)
+	case !mode.IsDir():
+		return fmt.Errorf("%s is not a directory", path)
+	case mode.Perm()&0022 != 0 && mode&os.ModeSticky == 0:
+		return fmt.Errorf("%s is writable by other users, its mode is %v", path, mode.Perm())
+	case !isTrustedOwner(info):
+		return fmt.Errorf("%s is owned by another user", path)
+	}
+
+	memory, err := isMemoryBacked(path)
+	if err != nil {
+		return fmt.Errorf("unable to check filesystem of %s: %w", path, err)
+	}
+	if !memory {
+		if requireMemory {
+			return fmt.Errorf("%s is not on a memory backed filesystem", path)
+		}
+		_log.Infow("kubeconfigs are not kept on a memory backed filesystem", "path", path)
+	}
+	return nil
+}
+
+// writeKubeConfig writes the kubeconfig readable only by the user of the
+// process, it fails if the file exists.
+func writeKubeConfig(path string, kubeConfig []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(kubeConfig); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,32 @@
+//go:build linux
+// +build linux
+
+package debug
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	tmpfsMagic = 0x01021994
+	ramfsMagic = 0x858458f6
+)
+
+// isMemoryBacked reports whether path is on tmpfs or ramfs, so that files
+// written to it never reach a disk.
+func isMemoryBacked(path string) (bool, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return false, err
+	}
+	t := uint32(fs.Type)
+	return t == tmpfsMagic || t == ramfsMagic, nil
+}
+
+// isTrustedOwner reports whether the file is owned by the user of the
+// process or root.
+func isTrustedOwner(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return !ok || st.Uid == 0 || int(st.Uid) == os.Getuid()
+}
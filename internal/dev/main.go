@@ -81,10 +81,16 @@ func runAPI(wg *sync.WaitGroup, ctx context.Context) {
 		MaxAgeDays: 10,
 	}
 	auditLogger := audit.GetAuditLogger(&ao)
-	dh := debug.NewDebugHandler(sp, pp, ugp, tmpPath, kubectlBin, auditLogger)
+	if err := debug.CheckTempPath(tmpPath, false); err != nil {
+		_log.Fatalw("temp path can not be used", "path", tmpPath, "error", err)
+	}
+
+	sessions := debug.NewSessionManager()
+	dh := debug.NewDebugHandler(sp, pp, ugp, tmpPath, kubectlBin, auditLogger, debug.OptionSessionManager(sessions))
 
 	r.ServeFiles("/v2/debug/ui/*filepath", http.FS(ui.Files))
 	r.Handle("GET", "/v2/debug/prompt/project/:project/cluster/:cluster_name", dh)
+	r.GET("/v2/debug/sessions", sessions.ListHandler)
 
 	n := negroni.New(
 		negroni.NewRecovery(),
@@ -118,6 +124,9 @@ func runAPI(wg *sync.WaitGroup, ctx context.Context) {
 	_log.Infow("shutting down debug prompt server")
 	ctx, cancel = context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
+	if err := sessions.Shutdown(ctx); err != nil {
+		_log.Infow("unable to end all sessions", "error", err)
+	}
 	s.Shutdown(ctx)
 }
 
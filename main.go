@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +19,10 @@ import (
 	systemrpc "github.com/paralus/paralus/proto/rpc/system"
 	userrpc "github.com/paralus/paralus/proto/rpc/user"
 	"github.com/paralus/prompt/debug"
+	"github.com/paralus/prompt/pkg/kube"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
 	"github.com/urfave/negroni"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
@@ -29,6 +36,30 @@ const (
 	kubectlBinEnv = "KUBECTL_BIN"
 	auditFileEnv  = "AUDIT_LOG_FILE"
 	usernameEnv   = "USER_NAME"
+
+	pipelineTimeoutEnv = "PIPELINE_TIMEOUT"
+	outputLimitEnv     = "MAX_OUTPUT_BYTES"
+	policyFileEnv      = "COMMAND_POLICY_FILE"
+	interactiveFileEnv = "INTERACTIVE_COMMANDS_FILE"
+	watchTypesEnv      = "COMPLETION_WATCH_TYPES"
+	recordSessionsEnv  = "RECORD_SESSIONS"
+	recordingDirEnv    = "RECORDING_DIR"
+	recordInputEnv     = "RECORD_SESSION_INPUT"
+	auditorGroupsEnv   = "RECORDING_AUDITOR_GROUPS"
+	historyDirEnv      = "HISTORY_DIR"
+	historySizeEnv     = "HISTORY_SIZE"
+	aliasDirEnv        = "ALIAS_DIR"
+	fanoutWorkersEnv   = "FANOUT_WORKERS"
+	userSessionsEnv    = "MAX_SESSIONS_PER_USER"
+	sessionsEnv        = "MAX_SESSIONS"
+	idleTimeoutEnv     = "SESSION_IDLE_TIMEOUT"
+	idleWarningEnv     = "SESSION_IDLE_WARNING"
+	maxLifetimeEnv     = "SESSION_MAX_LIFETIME"
+	adminPortEnv       = "ADMIN_PORT"
+	adminTokenEnv      = "ADMIN_TOKEN"
+	kubeconfigDirEnv   = "KUBECONFIG_DIR"
+	requireTmpfsEnv    = "KUBECONFIG_REQUIRE_TMPFS"
+	drainGraceEnv      = "DRAIN_GRACE_PERIOD"
 )
 
 var (
@@ -39,6 +70,30 @@ var (
 	kubectlBin string
 	auditFile  string
 
+	pipelineTimeout time.Duration
+	outputLimit     int64
+	policyFile      string
+	interactiveFile string
+	watchTypes      int
+	recordSessions  bool
+	recordingDir    string
+	recordInput     bool
+	auditorGroups   []string
+	historyDir      string
+	historySize     int
+	aliasDir        string
+	fanoutWorkers   int
+	maxUserSessions int
+	maxSessions     int
+	idleTimeout     time.Duration
+	idleWarning     time.Duration
+	maxLifetime     time.Duration
+	adminPort       int
+	adminToken      string
+	kubeconfigDir   string
+	requireTmpfs    bool
+	drainGrace      time.Duration
+
 	sp  sentryrpcv2.SentryPool
 	pp  systemrpc.SystemPool
 	ugp userrpc.UGPool
@@ -60,6 +115,29 @@ func setup() {
 	viper.SetDefault(kubectlBinEnv, "/usr/local/bin/kubectl")
 	viper.SetDefault(auditFileEnv, "/var/log/ztka-prompt/audit.log")
 	viper.SetDefault(usernameEnv, "")
+	viper.SetDefault(pipelineTimeoutEnv, "5m")
+	viper.SetDefault(outputLimitEnv, kube.DefaultOutputLimit)
+	viper.SetDefault(policyFileEnv, "")
+	viper.SetDefault(interactiveFileEnv, "")
+	viper.SetDefault(watchTypesEnv, 0)
+	viper.SetDefault(recordSessionsEnv, false)
+	viper.SetDefault(recordingDirEnv, "")
+	viper.SetDefault(recordInputEnv, false)
+	viper.SetDefault(auditorGroupsEnv, "")
+	viper.SetDefault(historyDirEnv, "")
+	viper.SetDefault(historySizeEnv, 1000)
+	viper.SetDefault(aliasDirEnv, "")
+	viper.SetDefault(fanoutWorkersEnv, kube.DefaultFanoutWorkers)
+	viper.SetDefault(userSessionsEnv, 0)
+	viper.SetDefault(sessionsEnv, 0)
+	viper.SetDefault(idleTimeoutEnv, 0)
+	viper.SetDefault(idleWarningEnv, "1m")
+	viper.SetDefault(maxLifetimeEnv, 0)
+	viper.SetDefault(adminPortEnv, 7010)
+	viper.SetDefault(adminTokenEnv, "")
+	viper.SetDefault(kubeconfigDirEnv, "")
+	viper.SetDefault(requireTmpfsEnv, false)
+	viper.SetDefault(drainGraceEnv, "20s")
 
 	viper.BindEnv(apiPortEnv)
 	viper.BindEnv(sentryAddrEnv)
@@ -68,6 +146,29 @@ func setup() {
 	viper.BindEnv(kubectlBinEnv)
 	viper.BindEnv(auditFileEnv)
 	viper.BindEnv(usernameEnv)
+	viper.BindEnv(pipelineTimeoutEnv)
+	viper.BindEnv(outputLimitEnv)
+	viper.BindEnv(policyFileEnv)
+	viper.BindEnv(interactiveFileEnv)
+	viper.BindEnv(watchTypesEnv)
+	viper.BindEnv(recordSessionsEnv)
+	viper.BindEnv(recordingDirEnv)
+	viper.BindEnv(recordInputEnv)
+	viper.BindEnv(auditorGroupsEnv)
+	viper.BindEnv(historyDirEnv)
+	viper.BindEnv(historySizeEnv)
+	viper.BindEnv(aliasDirEnv)
+	viper.BindEnv(fanoutWorkersEnv)
+	viper.BindEnv(userSessionsEnv)
+	viper.BindEnv(sessionsEnv)
+	viper.BindEnv(idleTimeoutEnv)
+	viper.BindEnv(idleWarningEnv)
+	viper.BindEnv(maxLifetimeEnv)
+	viper.BindEnv(adminPortEnv)
+	viper.BindEnv(adminTokenEnv)
+	viper.BindEnv(kubeconfigDirEnv)
+	viper.BindEnv(requireTmpfsEnv)
+	viper.BindEnv(drainGraceEnv)
 
 	apiPort = viper.GetInt(apiPortEnv)
 	sentryAddr = viper.GetString(sentryAddrEnv)
@@ -75,6 +176,42 @@ func setup() {
 	dev = viper.GetBool(devEnv)
 	kubectlBin = viper.GetString(kubectlBinEnv)
 	auditFile = viper.GetString(auditFileEnv)
+	pipelineTimeout = viper.GetDuration(pipelineTimeoutEnv)
+	outputLimit = viper.GetInt64(outputLimitEnv)
+	policyFile = viper.GetString(policyFileEnv)
+	interactiveFile = viper.GetString(interactiveFileEnv)
+	watchTypes = viper.GetInt(watchTypesEnv)
+	recordSessions = viper.GetBool(recordSessionsEnv)
+	recordingDir = viper.GetString(recordingDirEnv)
+	recordInput = viper.GetBool(recordInputEnv)
+	if recordSessions && recordingDir == "" {
+		recordingDir = filepath.Join(tmpPath, "recordings")
+	}
+	for _, g := range strings.Split(viper.GetString(auditorGroupsEnv), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			auditorGroups = append(auditorGroups, g)
+		}
+	}
+	historyDir = viper.GetString(historyDirEnv)
+	if historyDir == "" {
+		historyDir = filepath.Join(tmpPath, "history")
+	}
+	historySize = viper.GetInt(historySizeEnv)
+	aliasDir = viper.GetString(aliasDirEnv)
+	if aliasDir == "" {
+		aliasDir = filepath.Join(tmpPath, "aliases")
+	}
+	fanoutWorkers = viper.GetInt(fanoutWorkersEnv)
+	maxUserSessions = viper.GetInt(userSessionsEnv)
+	maxSessions = viper.GetInt(sessionsEnv)
+	idleTimeout = viper.GetDuration(idleTimeoutEnv)
+	idleWarning = viper.GetDuration(idleWarningEnv)
+	maxLifetime = viper.GetDuration(maxLifetimeEnv)
+	adminPort = viper.GetInt(adminPortEnv)
+	adminToken = viper.GetString(adminTokenEnv)
+	kubeconfigDir = viper.GetString(kubeconfigDirEnv)
+	requireTmpfs = viper.GetBool(requireTmpfsEnv)
+	drainGrace = viper.GetDuration(drainGraceEnv)
 
 	sp = sentryrpcv2.NewSentryPool(sentryAddr, 10)
 	pp = systemrpc.NewSystemPool(sentryAddr, 10)
@@ -94,10 +231,76 @@ func runAPI(wg *sync.WaitGroup, ctx context.Context) {
 	}
 	auditLogger := audit.GetAuditLogger(&ao)
 
-	dh := debug.NewDebugHandler(sp, pp, ugp, tmpPath, kubectlBin, auditLogger)
+	policy := kube.DefaultPolicy()
+	if policyFile != "" {
+		var err error
+		policy, err = kube.LoadPolicy(policyFile)
+		if err != nil {
+			_log.Fatalw("unable to load command policy", "file", policyFile, "error", err)
+		}
+	}
+
+	interactive := kube.DefaultInteractiveRules()
+	if interactiveFile != "" {
+		var err error
+		interactive, err = kube.LoadInteractiveRules(interactiveFile)
+		if err != nil {
+			_log.Fatalw("unable to load interactive commands", "file", interactiveFile, "error", err)
+		}
+	}
+
+	// kubeconfigs are written to the temp path unless a directory for them,
+	// e.g. on tmpfs, is configured.
+	if err := debug.CheckTempPath(tmpPath, requireTmpfs && kubeconfigDir == ""); err != nil {
+		_log.Fatalw("temp path can not be used", "path", tmpPath, "error", err)
+	}
+	if kubeconfigDir != "" {
+		if err := debug.CheckTempPath(kubeconfigDir, requireTmpfs); err != nil {
+			_log.Fatalw("kubeconfig dir can not be used", "path", kubeconfigDir, "error", err)
+		}
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if err := kube.RegisterMetrics(registry); err != nil {
+		_log.Fatalw("unable to register metrics", "error", err)
+	}
+	if err := debug.RegisterMetrics(registry); err != nil {
+		_log.Fatalw("unable to register metrics", "error", err)
+	}
+
+	sessions := debug.NewSessionManager()
+
+	opts := []debug.Option{
+		debug.OptionSessionManager(sessions),
+		debug.OptionPipelineTimeout(pipelineTimeout),
+		debug.OptionOutputLimit(outputLimit),
+		debug.OptionCommandPolicy(policy),
+		debug.OptionInteractiveCommands(interactive),
+		debug.OptionCompletionWatch(watchTypes),
+		debug.OptionHistory(historyDir, historySize),
+		debug.OptionAliasDir(aliasDir),
+		debug.OptionFanoutWorkers(fanoutWorkers),
+		debug.OptionSessionLimits(maxUserSessions, maxSessions),
+		debug.OptionIdleTimeout(idleTimeout, idleWarning),
+		debug.OptionMaxSessionLifetime(maxLifetime),
+		debug.OptionKubeconfigDir(kubeconfigDir),
+	}
+	if recordSessions {
+		opts = append(opts, debug.OptionRecordingDir(recordingDir), debug.OptionRecordInput(recordInput))
+	}
+	dh := debug.NewDebugHandler(sp, pp, ugp, tmpPath, kubectlBin, auditLogger, opts...)
 
 	r := httprouter.New()
 	r.Handle("GET", "/v2/debug/prompt/project/:project/cluster/:cluster_name", dh)
+	if recordSessions {
+		rh := debug.NewRecordingHandler(recordingDir, auditorGroups)
+		r.Handle("GET", "/v2/debug/prompt/project/:project/cluster/:cluster_name/recordings", rh.List)
+		r.Handle("GET", "/v2/debug/prompt/project/:project/cluster/:cluster_name/recordings/:recording_id", rh.Download)
+	}
 
 	n := negroni.New(
 		negroni.NewRecovery(),
@@ -127,16 +330,68 @@ func runAPI(wg *sync.WaitGroup, ctx context.Context) {
 		}
 	}()
 
+	// the admin server is not behind the auth middleware, probes and metrics
+	// are served to anyone reaching it, the sessions only with ADMIN_TOKEN.
+	admin := http.Server{
+		Addr:    fmt.Sprintf(":%d", adminPort),
+		Handler: adminRouter(sessions, debug.NewHealthHandler(sp, kubectlBin, sessions), registry, adminToken),
+	}
+	if adminPort > 0 {
+		go func() {
+			_log.Infow("starting admin server", "port", adminPort)
+			err := admin.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				_log.Fatalw("unable to start admin server", "error", err)
+			}
+		}()
+	}
+
 	// cleanup unused system sessions cachedir
 	pctx, pcancel := context.WithCancel(context.Background())
 	defer pcancel()
 	go debug.PruneCacheDirs(pctx, tmpPath)
+	if kubeconfigDir != "" && kubeconfigDir != tmpPath {
+		go debug.PruneCacheDirs(pctx, kubeconfigDir)
+	}
 
 	<-ctx.Done()
 	_log.Infow("shutting down debug prompt server")
-	ctx, cancel = context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel = context.WithTimeout(context.Background(), drainGrace+time.Second*10)
 	defer cancel()
+	// hijacked websockets are not closed by the server, the sessions are
+	// drained first so that their kubeconfigs are removed. New sessions are
+	// rejected and readyz fails meanwhile.
+	if err := sessions.Drain(ctx, drainGrace); err != nil {
+		_log.Infow("unable to end all sessions", "error", err)
+	}
 	s.Shutdown(ctx)
+	admin.Shutdown(ctx)
+}
+
+// adminRouter serves the endpoints for operators. The sessions expose
+// usernames and clusters, they are only served with token.
+func adminRouter(sessions *debug.SessionManager, health *debug.HealthHandler, registry *prometheus.Registry, token string) http.Handler {
+	r := httprouter.New()
+	r.GET("/healthz", health.Healthz)
+	r.GET("/readyz", health.Readyz)
+	if token != "" {
+		r.GET("/sessions", requireToken(token, sessions.ListHandler))
+	}
+	r.Handler("GET", "/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return r
+}
+
+// requireToken serves h only to requests with the bearer token.
+func requireToken(token string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h(w, r, ps)
+	}
 }
 
 func run() {
@@ -0,0 +1,142 @@
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxAliases is the number of aliases a user can define.
+const maxAliases = 200
+
+var aliasName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// Aliases are the command aliases of a user, e.g. k for get pods -o wide.
+// They are stored in a json file, so that they are kept across sessions.
+type Aliases struct {
+	m       sync.RWMutex
+	path    string
+	aliases map[string]string
+}
+
+// LoadAliases returns the aliases stored in the file at path, the aliases
+// are only kept for the session if path is empty.
+func LoadAliases(path string) (*Aliases, error) {
+	a := &Aliases{path: path, aliases: map[string]string{}}
+	if path == "" {
+		return a, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &a.aliases); err != nil {
+		return nil, fmt.Errorf("unable to parse aliases %s: %w", path, err)
+	}
+	return a, nil
+}
+
+// Get returns the command of the alias.
+func (a *Aliases) Get(name string) (string, bool) {
+	a.m.RLock()
+	defer a.m.RUnlock()
+	command, ok := a.aliases[name]
+	return command, ok
+}
+
+// Names returns the names of the aliases, sorted.
+func (a *Aliases) Names() []string {
+	a.m.RLock()
+	defer a.m.RUnlock()
+	names := make([]string, 0, len(a.aliases))
+	for name := range a.aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set defines the alias and stores the aliases.
+func (a *Aliases) Set(name, command string) error {
+	if !aliasName.MatchString(name) {
+		return fmt.Errorf("invalid alias name %q", name)
+	}
+	if isBuiltin(name) {
+		return fmt.Errorf("%s is a builtin command", name)
+	}
+	if strings.TrimSpace(command) == "" {
+		return fmt.Errorf("empty command for alias %s", name)
+	}
+
+	a.m.Lock()
+	defer a.m.Unlock()
+	if _, ok := a.aliases[name]; !ok && len(a.aliases) >= maxAliases {
+		return fmt.Errorf("too many aliases, at most %d can be defined", maxAliases)
+	}
+	a.aliases[name] = command
+	return a.save()
+}
+
+// Delete removes the alias and stores the aliases, it returns false if the
+// alias is not defined.
+func (a *Aliases) Delete(name string) (bool, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if _, ok := a.aliases[name]; !ok {
+		return false, nil
+	}
+	delete(a.aliases, name)
+	return true, a.save()
+}
+
+// Expand replaces an alias in the first word of s with its command, aliases
+// are not expanded recursively.
+func (a *Aliases) Expand(s string) (string, bool) {
+	name, rest := s, ""
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		name, rest = s[:i], s[i:]
+	}
+	command, ok := a.Get(name)
+	if !ok {
+		return s, false
+	}
+	return command + rest, true
+}
+
+func (a *Aliases) save() error {
+	if a.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(a.aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), a.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
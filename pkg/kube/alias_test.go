@@ -0,0 +1,123 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paralus/prompt/pkg/prompt"
+)
+
+func TestAliases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases", "alice.json")
+	a, err := LoadAliases(path)
+	if err != nil {
+		t.Fatalf("LoadAliases() error = %v", err)
+	}
+	if err := a.Set("wide", "get pods -o wide --sort-by=.status.startTime"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := a.Set("k", "get"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	for _, name := range []string{"alias", "clear", "", "a b", "../x"} {
+		if err := a.Set(name, "get pods"); err == nil {
+			t.Errorf("Set(%q) should fail", name)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("aliases stored with mode %v, want 0600", info.Mode().Perm())
+	}
+
+	a, err = LoadAliases(path)
+	if err != nil {
+		t.Fatalf("LoadAliases() error = %v", err)
+	}
+	if names := a.Names(); !reflect.DeepEqual(names, []string{"k", "wide"}) {
+		t.Errorf("Names() = %v", names)
+	}
+
+	for input, expected := range map[string]string{
+		"wide -n prod": "get pods -o wide --sort-by=.status.startTime -n prod",
+		"wide":         "get pods -o wide --sort-by=.status.startTime",
+		"k nodes":      "get nodes",
+		"kk nodes":     "kk nodes",
+		"get k":        "get k",
+	} {
+		if actual, _ := a.Expand(input); actual != expected {
+			t.Errorf("Expand(%q) = %q, want %q", input, actual, expected)
+		}
+	}
+
+	if ok, err := a.Delete("k"); !ok || err != nil {
+		t.Errorf("Delete() = %v, %v", ok, err)
+	}
+	if ok, _ := a.Delete("k"); ok {
+		t.Errorf("Delete() of a removed alias should return false")
+	}
+	a, _ = LoadAliases(path)
+	if _, ok := a.Get("k"); ok {
+		t.Errorf("removed alias is loaded")
+	}
+}
+
+func TestAliasBuiltins(t *testing.T) {
+	var out bytes.Buffer
+	a, _ := LoadAliases("")
+	e := &ioExecutor{rw: &out, aliases: a}
+
+	for _, s := range []string{
+		"alias wide='get pods -o wide'",
+		`alias dep="kubectl get deploy"`,
+		"alias k=get",
+		"unalias k",
+		"alias",
+	} {
+		if !e.runBuiltin(context.Background(), s) {
+			t.Fatalf("%q is not run as builtin", s)
+		}
+	}
+	expected := "alias dep='get deploy'\r\nalias wide='get pods -o wide'\r\n"
+	if out.String() != expected {
+		t.Errorf("output = %q, want %q", out.String(), expected)
+	}
+
+	out.Reset()
+	e.runBuiltin(context.Background(), "unalias k")
+	e.runBuiltin(context.Background(), "alias clear=get")
+	if !strings.Contains(out.String(), "error: alias k not found") || !strings.Contains(out.String(), "error: clear is a builtin command") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	for _, s := range []string{"get pods", "aliasx", "clear"} {
+		if e.runBuiltin(context.Background(), s) {
+			t.Errorf("%q is run as builtin", s)
+		}
+	}
+}
+
+func TestCompleteAliases(t *testing.T) {
+	a, _ := LoadAliases("")
+	a.Set("wide", "get pods -o wide")
+	c := &Completer{aliases: a}
+
+	b := prompt.NewBuffer()
+	b.InsertText("wide -n pr", false, true)
+	if d := c.expandAlias(*b.Document()); d.TextBeforeCursor() != "get pods -o wide -n pr" {
+		t.Errorf("expandAlias() = %q", d.TextBeforeCursor())
+	}
+
+	suggestions := c.argumentsCompleter("default", []string{"wi"})
+	if !reflect.DeepEqual(suggestions, []prompt.Suggest{{Text: "wide", Description: "alias: get pods -o wide"}}) {
+		t.Errorf("argumentsCompleter() = %v", suggestions)
+	}
+}
@@ -11,7 +11,6 @@ var commands = []prompt.Suggest{
 	{Text: "delete", Description: "Delete resources by filenames, stdin, resources and names, or by resources and label selector."},
 	{Text: "edit", Description: "Edit a resource on the server"},
 	{Text: "apply", Description: "Apply a configuration to a resource by filename or stdin"},
-	{Text: "namespace", Description: "SUPERSEDED: Set and view the current Kubernetes namespace"},
 	{Text: "logs", Description: "Print the logs for a container in a pod."},
 	{Text: "rolling-update", Description: "Perform a rolling update of the given ReplicationController."},
 	{Text: "scale", Description: "Set a new size for a Deployment, ReplicaSet, Replication Controller, or Job."},
@@ -40,8 +39,8 @@ var commands = []prompt.Suggest{
 	{Text: "exit", Description: "Exit this program"},
 }
 
+// resourceTypes are suggested until the resource types of the cluster are discovered.
 var resourceTypes = []prompt.Suggest{
-	{Text: "clusters"}, // valid only for federation apiservers
 	{Text: "componentstatuses"},
 	{Text: "configmaps"},
 	{Text: "daemonsets"},
@@ -58,8 +57,7 @@ var resourceTypes = []prompt.Suggest{
 	{Text: "nodes"},
 	{Text: "persistentvolumeclaims"},
 	{Text: "persistentvolumes"},
-	{Text: "pod"},
-	{Text: "podsecuritypolicies"},
+	{Text: "pods"},
 	{Text: "podtemplates"},
 	{Text: "replicasets"},
 	{Text: "replicationcontrollers"},
@@ -69,7 +67,6 @@ var resourceTypes = []prompt.Suggest{
 	{Text: "services"},
 	{Text: "statefulsets"},
 	{Text: "storageclasses"},
-	{Text: "thirdpartyresources"},
 
 	// aliases
 	{Text: "cs"},
@@ -85,11 +82,12 @@ var resourceTypes = []prompt.Suggest{
 	{Text: "pvc"},
 	{Text: "pv"},
 	{Text: "po"},
-	{Text: "psp"},
 	{Text: "rs"},
 	{Text: "rc"},
 	{Text: "quota"},
 	{Text: "sa"},
+	{Text: "sts"},
+	{Text: "cj"},
 	{Text: "svc"},
 }
 
@@ -98,166 +96,19 @@ func (c *Completer) argumentsCompleter(namespace string, args []string) []prompt
 		return []prompt.Suggest{}
 	}
 	if len(args) == 1 {
-		return prompt.FilterHasPrefix(commands, args[0], true)
+		suggestions := append(c.aliasSuggestions(), commands...)
+		suggestions = append(suggestions, builtinCommands...)
+		return prompt.FilterHasPrefix(suggestions, args[0], true)
 	}
 
 	first := args[0]
 	switch first {
-	case "get":
-		second := args[1]
-		if len(args) == 2 {
-			subcommands := []prompt.Suggest{
-				{Text: "componentstatuses"},
-				{Text: "configmaps"},
-				{Text: "daemonsets"},
-				{Text: "deployments"},
-				{Text: "endpoints"},
-				{Text: "events"},
-				{Text: "horizontalpodautoscalers"},
-				{Text: "ingresses"},
-				{Text: "jobs"},
-				{Text: "cronjobs"},
-				{Text: "limitranges"},
-				{Text: "namespaces"},
-				{Text: "networkpolicies"},
-				{Text: "nodes"},
-				{Text: "persistentvolumeclaims"},
-				{Text: "persistentvolumes"},
-				{Text: "pod"},
-				{Text: "podsecuritypolicies"},
-				{Text: "podtemplates"},
-				{Text: "replicasets"},
-				{Text: "replicationcontrollers"},
-				{Text: "resourcequotas"},
-				{Text: "secrets"},
-				{Text: "serviceaccounts"},
-				{Text: "services"},
-				{Text: "statefulsets"},
-				{Text: "storageclasses"},
-				{Text: "thirdpartyresources"},
-				// aliases
-				{Text: "cs"},
-				{Text: "cm"},
-				{Text: "ds"},
-				{Text: "deploy"},
-				{Text: "ep"},
-				{Text: "hpa"},
-				{Text: "ing"},
-				{Text: "limits"},
-				{Text: "ns"},
-				{Text: "no"},
-				{Text: "pvc"},
-				{Text: "pv"},
-				{Text: "po"},
-				{Text: "psp"},
-				{Text: "rs"},
-				{Text: "rc"},
-				{Text: "quota"},
-				{Text: "sa"},
-				{Text: "svc"},
-			}
-			return prompt.FilterHasPrefix(subcommands, second, true)
-		}
-
-		third := args[2]
-		if len(args) == 3 {
-			switch second {
-			case "componentstatuses", "cs":
-				return prompt.FilterContains(getComponentStatusCompletions(c.client), third, true)
-			case "configmaps", "cm":
-				return prompt.FilterContains(getConfigMapSuggestions(c.client, namespace), third, true)
-			case "daemonsets", "ds":
-				return prompt.FilterContains(getDaemonSetSuggestions(c.client, namespace), third, true)
-			case "deploy", "deployments":
-				return prompt.FilterContains(getDeploymentSuggestions(c.client, namespace), third, true)
-			case "endpoints", "ep":
-				return prompt.FilterContains(getEndpointsSuggestions(c.client, namespace), third, true)
-			case "ingresses", "ing":
-				return prompt.FilterContains(getIngressSuggestions(c.client, namespace), third, true)
-			case "limitranges", "limits":
-				return prompt.FilterContains(getLimitRangeSuggestions(c.client, namespace), third, true)
-			case "namespaces", "ns":
-				return prompt.FilterContains(getNameSpaceSuggestions(c.namespaceList), third, true)
-			case "no", "nodes":
-				return prompt.FilterContains(getNodeSuggestions(c.client), third, true)
-			case "po", "pod", "pods":
-				return prompt.FilterContains(getPodSuggestions(c.client, namespace), third, true)
-			case "persistentvolumeclaims", "pvc":
-				return prompt.FilterContains(getPersistentVolumeClaimSuggestions(c.client, namespace), third, true)
-			case "persistentvolumes", "pv":
-				return prompt.FilterContains(getPersistentVolumeSuggestions(c.client), third, true)
-			case "podsecuritypolicies", "psp":
-				return prompt.FilterContains(getPodSecurityPolicySuggestions(c.client), third, true)
-			case "podtemplates":
-				return prompt.FilterContains(getPodTemplateSuggestions(c.client, namespace), third, true)
-			case "replicasets", "rs":
-				return prompt.FilterContains(getReplicaSetSuggestions(c.client, namespace), third, true)
-			case "replicationcontrollers", "rc":
-				return prompt.FilterContains(getReplicationControllerSuggestions(c.client, namespace), third, true)
-			case "resourcequotas", "quota":
-				return prompt.FilterContains(getResourceQuotasSuggestions(c.client, namespace), third, true)
-			case "secrets":
-				return prompt.FilterContains(getSecretSuggestions(c.client, namespace), third, true)
-			case "sa", "serviceaccounts":
-				return prompt.FilterContains(getServiceAccountSuggestions(c.client, namespace), third, true)
-			case "svc", "services":
-				return prompt.FilterContains(getServiceSuggestions(c.client, namespace), third, true)
-			case "job", "jobs":
-				return prompt.FilterContains(getJobSuggestions(c.client, namespace), third, true)
-			}
-		}
-	case "describe":
-		second := args[1]
+	case "get", "describe", "delete", "edit":
 		if len(args) == 2 {
-			return prompt.FilterHasPrefix(resourceTypes, second, true)
+			return c.getResourceTypeSuggestions(args[1])
 		}
-
-		third := args[2]
 		if len(args) == 3 {
-			switch second {
-			case "componentstatuses", "cs":
-				return prompt.FilterContains(getComponentStatusCompletions(c.client), third, true)
-			case "configmaps", "cm":
-				return prompt.FilterContains(getConfigMapSuggestions(c.client, namespace), third, true)
-			case "daemonsets", "ds":
-				return prompt.FilterContains(getDaemonSetSuggestions(c.client, namespace), third, true)
-			case "deploy", "deployments":
-				return prompt.FilterContains(getDeploymentSuggestions(c.client, namespace), third, true)
-			case "endpoints", "ep":
-				return prompt.FilterContains(getEndpointsSuggestions(c.client, namespace), third, true)
-			case "ingresses", "ing":
-				return prompt.FilterContains(getIngressSuggestions(c.client, namespace), third, true)
-			case "limitranges", "limits":
-				return prompt.FilterContains(getLimitRangeSuggestions(c.client, namespace), third, true)
-			case "namespaces", "ns":
-				return prompt.FilterContains(getNameSpaceSuggestions(c.namespaceList), third, true)
-			case "no", "nodes":
-				return prompt.FilterContains(getNodeSuggestions(c.client), third, true)
-			case "po", "pod", "pods":
-				return prompt.FilterContains(getPodSuggestions(c.client, namespace), third, true)
-			case "persistentvolumeclaims", "pvc":
-				return prompt.FilterContains(getPersistentVolumeClaimSuggestions(c.client, namespace), third, true)
-			case "persistentvolumes", "pv":
-				return prompt.FilterContains(getPersistentVolumeSuggestions(c.client), third, true)
-			case "podsecuritypolicies", "psp":
-				return prompt.FilterContains(getPodSecurityPolicySuggestions(c.client), third, true)
-			case "podtemplates":
-				return prompt.FilterContains(getPodTemplateSuggestions(c.client, namespace), third, true)
-			case "replicasets", "rs":
-				return prompt.FilterContains(getReplicaSetSuggestions(c.client, namespace), third, true)
-			case "replicationcontrollers", "rc":
-				return prompt.FilterContains(getReplicationControllerSuggestions(c.client, namespace), third, true)
-			case "resourcequotas", "quota":
-				return prompt.FilterContains(getResourceQuotasSuggestions(c.client, namespace), third, true)
-			case "secrets":
-				return prompt.FilterContains(getSecretSuggestions(c.client, namespace), third, true)
-			case "sa", "serviceaccounts":
-				return prompt.FilterContains(getServiceAccountSuggestions(c.client, namespace), third, true)
-			case "svc", "services":
-				return prompt.FilterContains(getServiceSuggestions(c.client, namespace), third, true)
-			case "job", "jobs":
-				return prompt.FilterContains(getJobSuggestions(c.client, namespace), third, true)
-			}
+			return prompt.FilterContains(c.getNameSuggestions(namespace, args[1]), args[2], true)
 		}
 	case "create":
 		subcommands := []prompt.Suggest{
@@ -272,132 +123,34 @@ func (c *Completer) argumentsCompleter(namespace string, args []string) []prompt
 		if len(args) == 2 {
 			return prompt.FilterHasPrefix(subcommands, args[1], true)
 		}
-	case "delete":
-		second := args[1]
-		if len(args) == 2 {
-			return prompt.FilterHasPrefix(resourceTypes, second, true)
-		}
-
-		third := args[2]
-		if len(args) == 3 {
-			switch second {
-			case "componentstatuses", "cs":
-				return prompt.FilterContains(getComponentStatusCompletions(c.client), third, true)
-			case "configmaps", "cm":
-				return prompt.FilterContains(getConfigMapSuggestions(c.client, namespace), third, true)
-			case "daemonsets", "ds":
-				return prompt.FilterContains(getDaemonSetSuggestions(c.client, namespace), third, true)
-			case "deploy", "deployments":
-				return prompt.FilterContains(getDeploymentSuggestions(c.client, namespace), third, true)
-			case "endpoints", "ep":
-				return prompt.FilterContains(getEndpointsSuggestions(c.client, namespace), third, true)
-			case "ingresses", "ing":
-				return prompt.FilterContains(getIngressSuggestions(c.client, namespace), third, true)
-			case "limitranges", "limits":
-				return prompt.FilterContains(getLimitRangeSuggestions(c.client, namespace), third, true)
-			case "namespaces", "ns":
-				return prompt.FilterContains(getNameSpaceSuggestions(c.namespaceList), third, true)
-			case "no", "nodes":
-				return prompt.FilterContains(getNodeSuggestions(c.client), third, true)
-			case "po", "pod", "pods":
-				return prompt.FilterContains(getPodSuggestions(c.client, namespace), third, true)
-			case "persistentvolumeclaims", "pvc":
-				return prompt.FilterContains(getPersistentVolumeClaimSuggestions(c.client, namespace), third, true)
-			case "persistentvolumes", "pv":
-				return prompt.FilterContains(getPersistentVolumeSuggestions(c.client), third, true)
-			case "podsecuritypolicies", "psp":
-				return prompt.FilterContains(getPodSecurityPolicySuggestions(c.client), third, true)
-			case "podtemplates":
-				return prompt.FilterContains(getPodTemplateSuggestions(c.client, namespace), third, true)
-			case "replicasets", "rs":
-				return prompt.FilterContains(getReplicaSetSuggestions(c.client, namespace), third, true)
-			case "replicationcontrollers", "rc":
-				return prompt.FilterContains(getReplicationControllerSuggestions(c.client, namespace), third, true)
-			case "resourcequotas", "quota":
-				return prompt.FilterContains(getResourceQuotasSuggestions(c.client, namespace), third, true)
-			case "secrets":
-				return prompt.FilterContains(getSecretSuggestions(c.client, namespace), third, true)
-			case "sa", "serviceaccounts":
-				return prompt.FilterContains(getServiceAccountSuggestions(c.client, namespace), third, true)
-			case "svc", "services":
-				return prompt.FilterContains(getServiceSuggestions(c.client, namespace), third, true)
-			case "job", "jobs":
-				return prompt.FilterContains(getJobSuggestions(c.client, namespace), third, true)
+	case "use-cluster", "fanout":
+		if len(args) == 2 && c.clusterNames != nil {
+			var suggestions []prompt.Suggest
+			for _, name := range c.clusterNames() {
+				suggestions = append(suggestions, prompt.Suggest{Text: name})
 			}
+			return prompt.FilterContains(suggestions, args[1], true)
 		}
-	case "edit":
-		if len(args) == 2 {
-			return prompt.FilterHasPrefix(resourceTypes, args[1], true)
-		}
-
-		if len(args) == 3 {
-			third := args[2]
-			switch args[1] {
-			case "componentstatuses", "cs":
-				return prompt.FilterContains(getComponentStatusCompletions(c.client), third, true)
-			case "configmaps", "cm":
-				return prompt.FilterContains(getConfigMapSuggestions(c.client, namespace), third, true)
-			case "daemonsets", "ds":
-				return prompt.FilterContains(getDaemonSetSuggestions(c.client, namespace), third, true)
-			case "deploy", "deployments":
-				return prompt.FilterContains(getDeploymentSuggestions(c.client, namespace), third, true)
-			case "endpoints", "ep":
-				return prompt.FilterContains(getEndpointsSuggestions(c.client, namespace), third, true)
-			case "ingresses", "ing":
-				return prompt.FilterContains(getIngressSuggestions(c.client, namespace), third, true)
-			case "limitranges", "limits":
-				return prompt.FilterContains(getLimitRangeSuggestions(c.client, namespace), third, true)
-			case "namespaces", "ns":
-				return prompt.FilterContains(getNameSpaceSuggestions(c.namespaceList), third, true)
-			case "no", "nodes":
-				return prompt.FilterContains(getNodeSuggestions(c.client), third, true)
-			case "po", "pod", "pods":
-				return prompt.FilterContains(getPodSuggestions(c.client, namespace), third, true)
-			case "persistentvolumeclaims", "pvc":
-				return prompt.FilterContains(getPersistentVolumeClaimSuggestions(c.client, namespace), third, true)
-			case "persistentvolumes", "pv":
-				return prompt.FilterContains(getPersistentVolumeSuggestions(c.client), third, true)
-			case "podsecuritypolicies", "psp":
-				return prompt.FilterContains(getPodSecurityPolicySuggestions(c.client), third, true)
-			case "podtemplates":
-				return prompt.FilterContains(getPodTemplateSuggestions(c.client, namespace), third, true)
-			case "replicasets", "rs":
-				return prompt.FilterContains(getReplicaSetSuggestions(c.client, namespace), third, true)
-			case "replicationcontrollers", "rc":
-				return prompt.FilterContains(getReplicationControllerSuggestions(c.client, namespace), third, true)
-			case "resourcequotas", "quota":
-				return prompt.FilterContains(getResourceQuotasSuggestions(c.client, namespace), third, true)
-			case "secrets":
-				return prompt.FilterContains(getSecretSuggestions(c.client, namespace), third, true)
-			case "sa", "serviceaccounts":
-				return prompt.FilterContains(getServiceAccountSuggestions(c.client, namespace), third, true)
-			case "svc", "services":
-				return prompt.FilterContains(getServiceSuggestions(c.client, namespace), third, true)
-			case "job", "jobs":
-				return prompt.FilterContains(getJobSuggestions(c.client, namespace), third, true)
-			}
-		}
-
-	case "namespace":
+	case "use-namespace", "ns":
 		if len(args) == 2 {
 			return prompt.FilterContains(getNameSpaceSuggestions(c.namespaceList), args[1], true)
 		}
 	case "logs":
 		if len(args) == 2 {
-			return prompt.FilterContains(getPodSuggestions(c.client, namespace), args[1], true)
+			return prompt.FilterContains(c.getPodSuggestions(namespace), args[1], true)
 		}
 	case "rolling-update", "rollingupdate":
 		if len(args) == 2 {
-			return prompt.FilterContains(getReplicationControllerSuggestions(c.client, namespace), args[1], true)
+			return prompt.FilterContains(c.getReplicationControllerSuggestions(namespace), args[1], true)
 		} else if len(args) == 3 {
-			return prompt.FilterContains(getReplicationControllerSuggestions(c.client, namespace), args[2], true)
+			return prompt.FilterContains(c.getReplicationControllerSuggestions(namespace), args[2], true)
 		}
 	case "scale", "resize":
 		if len(args) == 2 {
 			// Deployment, ReplicaSet, Replication Controller, or Job.
-			r := getDeploymentSuggestions(c.client, namespace)
-			r = append(r, getReplicaSetSuggestions(c.client, namespace)...)
-			r = append(r, getReplicationControllerSuggestions(c.client, namespace)...)
+			r := c.getDeploymentSuggestions(namespace)
+			r = append(r, c.getReplicaSetSuggestions(namespace)...)
+			r = append(r, c.getReplicationControllerSuggestions(namespace)...)
 			return prompt.FilterContains(r, args[1], true)
 		}
 	case "cordon":
@@ -406,22 +159,22 @@ func (c *Completer) argumentsCompleter(namespace string, args []string) []prompt
 		fallthrough
 	case "uncordon":
 		if len(args) == 2 {
-			return prompt.FilterHasPrefix(getNodeSuggestions(c.client), args[1], true)
+			return prompt.FilterHasPrefix(c.getNodeSuggestions(), args[1], true)
 		}
 	case "attach":
 		if len(args) == 2 {
-			return prompt.FilterContains(getPodSuggestions(c.client, namespace), args[1], true)
+			return prompt.FilterContains(c.getPodSuggestions(namespace), args[1], true)
 		}
 	case "exec":
 		if len(args) == 2 {
-			return prompt.FilterContains(getPodSuggestions(c.client, namespace), args[1], true)
+			return prompt.FilterContains(c.getPodSuggestions(namespace), args[1], true)
 		}
 	case "port-forward":
 		if len(args) == 2 {
-			return prompt.FilterContains(getPodSuggestions(c.client, namespace), args[1], true)
+			return prompt.FilterContains(c.getPodSuggestions(namespace), args[1], true)
 		}
 		if len(args) == 3 {
-			return prompt.FilterHasPrefix(getPortsFromPodName(namespace, args[1]), args[2], true)
+			return prompt.FilterHasPrefix(c.getPortsFromPodName(namespace, args[1]), args[2], true)
 		}
 	case "rollout":
 		subCommands := []prompt.Suggest{
@@ -467,7 +220,7 @@ func (c *Completer) argumentsCompleter(namespace string, args []string) []prompt
 			return prompt.FilterHasPrefix(subCommands, args[1], true)
 		}
 	case "explain":
-		return prompt.FilterHasPrefix(resourceTypes, args[1], true)
+		return c.getResourceTypeSuggestions(args[1])
 	case "top":
 		second := args[1]
 		if len(args) == 2 {
@@ -485,9 +238,9 @@ func (c *Completer) argumentsCompleter(namespace string, args []string) []prompt
 		if len(args) == 3 {
 			switch second {
 			case "no", "node", "nodes":
-				return prompt.FilterContains(getNodeSuggestions(c.client), third, true)
+				return prompt.FilterContains(c.getNodeSuggestions(), third, true)
 			case "po", "pod", "pods":
-				return prompt.FilterContains(getPodSuggestions(c.client, namespace), third, true)
+				return prompt.FilterContains(c.getPodSuggestions(namespace), third, true)
 			}
 		}
 	default:
@@ -495,3 +248,68 @@ func (c *Completer) argumentsCompleter(namespace string, args []string) []prompt
 	}
 	return []prompt.Suggest{}
 }
+
+// getResourceTypeSuggestions returns the resource types served by the cluster,
+// or the builtin ones until they are discovered.
+func (c *Completer) getResourceTypeSuggestions(prefix string) []prompt.Suggest {
+	if rc := c.resourceCatalog(); rc != nil {
+		return rc.suggestions(prefix)
+	}
+	return prompt.FilterHasPrefix(resourceTypes, prefix, true)
+}
+
+// getNameSuggestions returns the object names of the resource type, which may
+// be given by any name kubectl accepts.
+func (c *Completer) getNameSuggestions(namespace, resource string) []prompt.Suggest {
+	if rc := c.resourceCatalog(); rc != nil {
+		if r, ok := rc.lookup(resource); ok {
+			return c.getResourceNameSuggestions(r, namespace)
+		}
+		return []prompt.Suggest{}
+	}
+
+	// resource types are not discovered yet
+	switch resource {
+	case "componentstatuses", "cs":
+		return c.getComponentStatusCompletions()
+	case "configmaps", "cm":
+		return c.getConfigMapSuggestions(namespace)
+	case "daemonsets", "ds":
+		return c.getDaemonSetSuggestions(namespace)
+	case "deploy", "deployments":
+		return c.getDeploymentSuggestions(namespace)
+	case "endpoints", "ep":
+		return c.getEndpointsSuggestions(namespace)
+	case "ingresses", "ing":
+		return c.getIngressSuggestions(namespace)
+	case "limitranges", "limits":
+		return c.getLimitRangeSuggestions(namespace)
+	case "namespaces", "ns":
+		return getNameSpaceSuggestions(c.namespaceList)
+	case "no", "nodes":
+		return c.getNodeSuggestions()
+	case "po", "pod", "pods":
+		return c.getPodSuggestions(namespace)
+	case "persistentvolumeclaims", "pvc":
+		return c.getPersistentVolumeClaimSuggestions(namespace)
+	case "persistentvolumes", "pv":
+		return c.getPersistentVolumeSuggestions()
+	case "podtemplates":
+		return c.getPodTemplateSuggestions(namespace)
+	case "replicasets", "rs":
+		return c.getReplicaSetSuggestions(namespace)
+	case "replicationcontrollers", "rc":
+		return c.getReplicationControllerSuggestions(namespace)
+	case "resourcequotas", "quota":
+		return c.getResourceQuotasSuggestions(namespace)
+	case "secrets":
+		return c.getSecretSuggestions(namespace)
+	case "sa", "serviceaccounts":
+		return c.getServiceAccountSuggestions(namespace)
+	case "svc", "services":
+		return c.getServiceSuggestions(namespace)
+	case "job", "jobs":
+		return c.getJobSuggestions(namespace)
+	}
+	return []prompt.Suggest{}
+}
@@ -0,0 +1,175 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-shellwords"
+	"github.com/paralus/prompt/pkg/prompt"
+)
+
+// builtinCommands are run by the executor instead of kubectl.
+var builtinCommands = []prompt.Suggest{
+	{Text: "alias", Description: "Define or display aliases, e.g. alias wide='get pods -o wide'"},
+	{Text: "unalias", Description: "Remove aliases"},
+	{Text: "aliases", Description: "Display aliases"},
+	{Text: "use-namespace", Description: "Set the namespace of the following commands, - for the previous one"},
+	{Text: "ns", Description: "Display or set the namespace of the following commands"},
+	{Text: "use-cluster", Description: "Display the clusters or set the cluster of the following commands"},
+	{Text: "fanout", Description: "Run a read-only command on many clusters, e.g. fanout 'prod-*' -- get nodes"},
+}
+
+// namespaceName is a valid namespace name, a DNS-1123 label.
+var namespaceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// isBuiltin reports whether name is a command of the prompt, which can not be
+// used as alias.
+func isBuiltin(name string) bool {
+	if name == "clear" || name == "exit" {
+		return true
+	}
+	for _, b := range builtinCommands {
+		if b.Text == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runBuiltin runs s if it is a builtin command, it returns false otherwise.
+func (e *ioExecutor) runBuiltin(ctx context.Context, s string) bool {
+	name, args := s, ""
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		name, args = s[:i], strings.TrimSpace(s[i:])
+	}
+	if !isBuiltin(name) || name == "clear" || name == "exit" {
+		return false
+	}
+
+	// audited with the event before running, use-cluster changes it
+	event := e.event
+	meta := map[string]string{"builtin": name}
+	switch name {
+	case "alias":
+		e.alias(args)
+	case "unalias":
+		e.unalias(args)
+	case "aliases":
+		e.listAliases()
+	case "use-namespace":
+		e.useNamespace(args)
+	case "ns":
+		if args == "" {
+			e.writeLine(e.namespace)
+			break
+		}
+		e.useNamespace(args)
+	case "use-cluster":
+		e.useCluster(ctx, args, meta)
+	case "fanout":
+		e.runFanout(ctx, args, meta)
+	}
+	createKubectlCommandAudit(event, "kubectl "+s, meta, e.auditLogger)
+	return true
+}
+
+// alias defines an alias given as name=command, the command may be quoted.
+// Without command the alias is displayed, without argument all aliases.
+func (e *ioExecutor) alias(args string) {
+	if args == "" {
+		e.listAliases()
+		return
+	}
+
+	i := strings.Index(args, "=")
+	if i < 0 {
+		command, ok := e.aliases.Get(args)
+		if !ok {
+			e.writeError(fmt.Errorf("alias %s not found", args))
+			return
+		}
+		e.writeLine(fmt.Sprintf("alias %s='%s'", args, command))
+		return
+	}
+
+	name, command := strings.TrimSpace(args[:i]), strings.TrimSpace(args[i+1:])
+	if strings.HasPrefix(command, "'") || strings.HasPrefix(command, `"`) {
+		words, err := shellwords.Parse(command)
+		if err != nil || len(words) != 1 {
+			e.writeError(fmt.Errorf("invalid quoting of alias %s", name))
+			return
+		}
+		command = words[0]
+	}
+	if strings.HasPrefix(command, "kubectl ") {
+		command = strings.TrimSpace(strings.TrimPrefix(command, "kubectl "))
+	}
+	if err := e.aliases.Set(name, command); err != nil {
+		e.writeError(err)
+	}
+}
+
+// unalias removes the aliases given by name.
+func (e *ioExecutor) unalias(args string) {
+	names := strings.Fields(args)
+	if len(names) == 0 {
+		e.writeError(fmt.Errorf("usage: unalias name [name ...]"))
+		return
+	}
+	for _, name := range names {
+		ok, err := e.aliases.Delete(name)
+		if err != nil {
+			e.writeError(err)
+			return
+		}
+		if !ok {
+			e.writeError(fmt.Errorf("alias %s not found", name))
+		}
+	}
+}
+
+func (e *ioExecutor) listAliases() {
+	for _, name := range e.aliases.Names() {
+		command, _ := e.aliases.Get(name)
+		e.writeLine(fmt.Sprintf("alias %s='%s'", name, command))
+	}
+}
+
+// useNamespace sets the namespace of the following commands, which do not set
+// one. The previous namespace is set again for -.
+func (e *ioExecutor) useNamespace(args string) {
+	ns := args
+	switch {
+	case ns == "":
+		e.writeError(fmt.Errorf("usage: use-namespace namespace"))
+		return
+	case ns == "-":
+		if e.previousNamespace == "" {
+			e.writeError(fmt.Errorf("no previous namespace"))
+			return
+		}
+		ns = e.previousNamespace
+	case len(ns) > 63 || !namespaceName.MatchString(ns):
+		e.writeError(fmt.Errorf("invalid namespace %q", ns))
+		return
+	}
+	if ns == e.namespace {
+		return
+	}
+
+	e.previousNamespace, e.namespace = e.namespace, ns
+	if e.onNamespaceChange != nil {
+		e.onNamespaceChange(ns)
+	}
+	_log.Infow("changed namespace", "from", e.previousNamespace, "to", ns)
+}
+
+// writeLine writes the text as a line to the terminal of the user.
+func (e *ioExecutor) writeLine(s string) {
+	_, err := e.rw.Write([]byte(s + "\r\n"))
+	if err != nil {
+		_log.Infow("unable to write output", "error", err)
+	}
+}
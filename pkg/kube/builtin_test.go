@@ -0,0 +1,61 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNamespaceBuiltins(t *testing.T) {
+	var out bytes.Buffer
+	var changed []string
+	e := &ioExecutor{
+		rw:   &out,
+		args: []string{"--kubeconfig", "/tmp/kubeconfig"},
+	}
+	OptionDefaultNamespace("default")(e)
+	OptionOnNamespaceChange(func(ns string) { changed = append(changed, ns) })(e)
+
+	e.runBuiltin(context.Background(), "use-namespace payments")
+	if e.namespace != "payments" {
+		t.Fatalf("namespace = %q, want payments", e.namespace)
+	}
+
+	scenarioTable := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "get pods", expected: []string{"--kubeconfig", "/tmp/kubeconfig", "--namespace", "payments"}},
+		{input: "get pods -n prod", expected: []string{"--kubeconfig", "/tmp/kubeconfig"}},
+		{input: "get pods --namespace=prod", expected: []string{"--kubeconfig", "/tmp/kubeconfig"}},
+		{input: "get pods -A", expected: []string{"--kubeconfig", "/tmp/kubeconfig"}},
+	}
+	for _, s := range scenarioTable {
+		actual := e.defaultArgs(parseCommand(strings.Fields(s.input)))
+		if !reflect.DeepEqual(actual, s.expected) {
+			t.Errorf("%q: should be %v, got %v", s.input, s.expected, actual)
+		}
+	}
+
+	e.runBuiltin(context.Background(), "ns")
+	e.runBuiltin(context.Background(), "use-namespace -")
+	e.runBuiltin(context.Background(), "ns")
+	if got := out.String(); got != "payments\r\ndefault\r\n" {
+		t.Errorf("output = %q", got)
+	}
+	if actual := e.defaultArgs(parseCommand([]string{"get", "pods"})); !reflect.DeepEqual(actual, e.args) {
+		t.Errorf("default namespace passed to kubectl: %v", actual)
+	}
+
+	out.Reset()
+	e.runBuiltin(context.Background(), "ns Invalid_Namespace")
+	e.runBuiltin(context.Background(), "use-namespace")
+	if e.namespace != "default" || !strings.Contains(out.String(), `error: invalid namespace "Invalid_Namespace"`) {
+		t.Errorf("namespace = %q, output = %q", e.namespace, out.String())
+	}
+	if !reflect.DeepEqual(changed, []string{"payments", "default"}) {
+		t.Errorf("changed = %v", changed)
+	}
+}
@@ -0,0 +1,70 @@
+package kube
+
+import (
+	"sync"
+	"time"
+)
+
+const thresholdFetchInterval = 10 * time.Second
+
+// resourceCache holds the objects listed for completion by a completer, so
+// that suggestions are never shared between sessions.
+type resourceCache struct {
+	m       sync.Mutex
+	entries map[string]*cacheEntry
+	closed  bool
+}
+
+type cacheEntry struct {
+	value     interface{}
+	fetchedAt time.Time
+}
+
+func newResourceCache() *resourceCache {
+	return &resourceCache{entries: map[string]*cacheEntry{}}
+}
+
+// get returns the value cached for key, nil if it was never listed. The value
+// is listed again in the background once it is older than thresholdFetchInterval.
+func (rc *resourceCache) get(key string, list func() (interface{}, error)) interface{} {
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	if rc.closed {
+		return nil
+	}
+
+	e, ok := rc.entries[key]
+	if !ok {
+		e = &cacheEntry{}
+		rc.entries[key] = e
+	}
+	if !ok || time.Since(e.fetchedAt) > thresholdFetchInterval {
+		// updated before listing, so that completing while the list is
+		// pending does not list again.
+		e.fetchedAt = time.Now()
+		go rc.fetch(key, list)
+	}
+	return e.value
+}
+
+func (rc *resourceCache) fetch(key string, list func() (interface{}, error)) {
+	v, err := list()
+	if err != nil {
+		_log.Debugw("unable to list resources for completion", "key", key, "error", err)
+		return
+	}
+
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	if e, ok := rc.entries[key]; ok && !rc.closed {
+		e.value = v
+	}
+}
+
+// close drops all cached values, values listed afterwards are not stored.
+func (rc *resourceCache) close() {
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	rc.closed = true
+	rc.entries = nil
+}
@@ -0,0 +1,76 @@
+package kube
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitForCache(rc *resourceCache, key string) interface{} {
+	for i := 0; i < 100; i++ {
+		rc.m.Lock()
+		e, ok := rc.entries[key]
+		var v interface{}
+		if ok {
+			v = e.value
+		}
+		rc.m.Unlock()
+		if v != nil {
+			return v
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestResourceCacheGet(t *testing.T) {
+	rc := newResourceCache()
+	var calls int32
+	list := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "listed", nil
+	}
+
+	if v := rc.get("a/default/pods", list); v != nil {
+		t.Errorf("should be nil before listing, got %v", v)
+	}
+	if v := waitForCache(rc, "a/default/pods"); v != "listed" {
+		t.Errorf("should be listed, got %v", v)
+	}
+	if v := rc.get("a/default/pods", list); v != "listed" {
+		t.Errorf("should be listed, got %v", v)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("should list once within the fetch interval, listed %d times", n)
+	}
+
+	// keys of other clusters are not shared
+	rc.get("b/default/pods", func() (interface{}, error) {
+		return nil, errors.New("forbidden")
+	})
+	time.Sleep(20 * time.Millisecond)
+	if v := rc.get("b/default/pods", list); v != nil {
+		t.Errorf("should be nil for other cluster, got %v", v)
+	}
+}
+
+func TestResourceCacheClose(t *testing.T) {
+	rc := newResourceCache()
+	release := make(chan struct{})
+	rc.get("a/default/pods", func() (interface{}, error) {
+		<-release
+		return "listed", nil
+	})
+
+	rc.close()
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+
+	if v := rc.get("a/default/pods", func() (interface{}, error) { return "listed", nil }); v != nil {
+		t.Errorf("should be nil after close, got %v", v)
+	}
+	if rc.entries != nil {
+		t.Errorf("should release entries on close, got %v", rc.entries)
+	}
+}
@@ -0,0 +1,94 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/paralus/paralus/pkg/audit"
+)
+
+// ClusterSwitcher lists the clusters of the user and switches the session to
+// another cluster.
+type ClusterSwitcher interface {
+	// Cluster returns the name of the cluster of the session.
+	Cluster() string
+	// Clusters returns the names of the clusters the user can access.
+	Clusters(ctx context.Context) ([]string, error)
+	// UseCluster switches the session to the cluster, the session is not
+	// changed if it fails.
+	UseCluster(ctx context.Context, name string) (*ClusterSession, error)
+}
+
+// ClusterSession is what commands are run with after switching clusters.
+type ClusterSession struct {
+	// Args are the default flags of kubectl, e.g. the kubeconfig.
+	Args []string
+	// Event is the audit event of the commands on the cluster.
+	Event *audit.Event
+	// Namespace is the namespace of the kubeconfig of the cluster.
+	Namespace string
+}
+
+// OptionClusterSwitcher to allow switching clusters with use-cluster.
+func OptionClusterSwitcher(s ClusterSwitcher) ExecutorOption {
+	return func(e *ioExecutor) {
+		e.clusters = s
+	}
+}
+
+// setArgs sets the default flags of kubectl, dropping empty ones.
+func (e *ioExecutor) setArgs(args []string) {
+	e.args = nil
+	for _, arg := range args {
+		if strings.TrimSpace(arg) != "" {
+			e.args = append(e.args, arg)
+		}
+	}
+}
+
+// useCluster switches the session to the cluster, without argument the
+// clusters are listed. The clusters switched from and to are added to meta.
+func (e *ioExecutor) useCluster(ctx context.Context, name string, meta map[string]string) {
+	if e.clusters == nil {
+		e.writeError(errors.New("switching clusters is not enabled"))
+		return
+	}
+
+	current := e.clusters.Cluster()
+	if name == "" {
+		names, err := e.clusters.Clusters(ctx)
+		if err != nil {
+			_log.Infow("unable to list clusters", "error", err)
+			e.writeError(fmt.Errorf("unable to list clusters: %v", err))
+			return
+		}
+		for _, n := range names {
+			if n == current {
+				e.writeLine("* " + n)
+			} else {
+				e.writeLine("  " + n)
+			}
+		}
+		return
+	}
+	if name == current {
+		return
+	}
+
+	meta["cluster_from"] = current
+	meta["cluster_to"] = name
+	cs, err := e.clusters.UseCluster(ctx, name)
+	if err != nil {
+		_log.Infow("unable to use cluster", "cluster", name, "error", err)
+		meta["error"] = err.Error()
+		e.writeError(fmt.Errorf("unable to use cluster %s: %v", name, err))
+		return
+	}
+
+	e.setArgs(cs.Args)
+	e.event = cs.Event
+	e.namespace, e.defaultNamespace, e.previousNamespace = cs.Namespace, cs.Namespace, ""
+	_log.Infow("changed cluster", "from", current, "to", name)
+}
@@ -0,0 +1,72 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeClusterSwitcher struct {
+	cluster  string
+	clusters []string
+}
+
+func (f *fakeClusterSwitcher) Cluster() string {
+	return f.cluster
+}
+
+func (f *fakeClusterSwitcher) Clusters(ctx context.Context) ([]string, error) {
+	return f.clusters, nil
+}
+
+func (f *fakeClusterSwitcher) UseCluster(ctx context.Context, name string) (*ClusterSession, error) {
+	for _, c := range f.clusters {
+		if c == name {
+			f.cluster = name
+			return &ClusterSession{Args: []string{"--kubeconfig", "/tmp/" + name, ""}, Namespace: "kube-system"}, nil
+		}
+	}
+	return nil, errors.New("cluster not found")
+}
+
+func TestUseCluster(t *testing.T) {
+	var out bytes.Buffer
+	e := &ioExecutor{rw: &out, args: []string{"--kubeconfig", "/tmp/dev"}}
+	OptionDefaultNamespace("default")(e)
+	OptionClusterSwitcher(&fakeClusterSwitcher{cluster: "dev", clusters: []string{"dev", "prod"}})(e)
+
+	e.runBuiltin(context.Background(), "use-namespace payments")
+	e.runBuiltin(context.Background(), "use-cluster")
+	if got := out.String(); got != "* dev\r\n  prod\r\n" {
+		t.Errorf("output = %q", got)
+	}
+
+	out.Reset()
+	e.runBuiltin(context.Background(), "use-cluster staging")
+	if !strings.Contains(out.String(), "error: unable to use cluster staging") {
+		t.Errorf("output = %q", out.String())
+	}
+	if e.namespace != "payments" || !reflect.DeepEqual(e.args, []string{"--kubeconfig", "/tmp/dev"}) {
+		t.Errorf("session changed by failed switch: namespace = %q, args = %v", e.namespace, e.args)
+	}
+
+	e.runBuiltin(context.Background(), "use-cluster prod")
+	if !reflect.DeepEqual(e.args, []string{"--kubeconfig", "/tmp/prod"}) {
+		t.Errorf("args = %v", e.args)
+	}
+	if e.namespace != "kube-system" || e.defaultNamespace != "kube-system" || e.previousNamespace != "" {
+		t.Errorf("namespace = %q, default = %q, previous = %q", e.namespace, e.defaultNamespace, e.previousNamespace)
+	}
+}
+
+func TestUseClusterDisabled(t *testing.T) {
+	var out bytes.Buffer
+	e := &ioExecutor{rw: &out}
+	e.runBuiltin(context.Background(), "use-cluster prod")
+	if !strings.Contains(out.String(), "error: switching clusters is not enabled") {
+		t.Errorf("output = %q", out.String())
+	}
+}
@@ -0,0 +1,263 @@
+package kube
+
+import (
+	"strings"
+)
+
+// command is a kubectl invocation parsed from its argv.
+type command struct {
+	// Verb is the kubectl subcommand, e.g. get or config view.
+	Verb string
+	// Resources are the canonical resource types the command acts on.
+	Resources []string
+	// Args are the positional arguments after the verb and resource types.
+	Args []string
+	// Namespace is the namespace given by flag, empty for the default one.
+	Namespace     string
+	AllNamespaces bool
+	// Flags holds flags by name, boolean flags have an empty value.
+	Flags map[string]string
+}
+
+// groupVerbs have subcommands which are part of the verb, e.g. config view.
+var groupVerbs = map[string]bool{
+	"config":      true,
+	"rollout":     true,
+	"set":         true,
+	"auth":        true,
+	"certificate": true,
+	"plugin":      true,
+}
+
+// verbs are the kubectl subcommands.
+var verbs = map[string]bool{
+	"create": true, "expose": true, "run": true, "set": true, "explain": true,
+	"get": true, "edit": true, "delete": true, "rollout": true, "scale": true,
+	"autoscale": true, "certificate": true, "cluster-info": true, "top": true,
+	"cordon": true, "uncordon": true, "drain": true, "taint": true,
+	"describe": true, "logs": true, "attach": true, "exec": true,
+	"port-forward": true, "proxy": true, "cp": true, "auth": true,
+	"debug": true, "events": true, "diff": true, "apply": true, "patch": true,
+	"replace": true, "wait": true, "kustomize": true, "label": true,
+	"annotate": true, "completion": true, "alpha": true,
+	"api-resources": true, "api-versions": true, "config": true,
+	"plugin": true, "version": true, "options": true, "help": true,
+}
+
+// valueFlags take the following argument as value unless given as --flag=value.
+// They include all global flags of kubectl taking a value, as these may come
+// before the verb.
+var valueFlags = map[string]bool{
+	"-n": true, "--namespace": true,
+	"-o": true, "--output": true,
+	"-l": true, "--selector": true,
+	"-c": true, "--container": true,
+	"-L": true, "--label-columns": true,
+	"-p": true, "--patch": true,
+	"-s": true, "--server": true,
+	"-v": true, "--v": true,
+	"--filename": true, "--kubeconfig": true, "--context": true,
+	"--cluster": true, "--user": true, "--token": true,
+	"--username": true, "--password": true,
+	"--as": true, "--as-group": true, "--as-uid": true,
+	"--certificate-authority": true, "--client-certificate": true,
+	"--client-key": true, "--tls-server-name": true,
+	"--profile": true, "--profile-output": true, "--vmodule": true,
+	"--log-backtrace-at": true, "--log-dir": true, "--log-file": true,
+	"--log-file-max-size": true, "--log-flush-frequency": true,
+	"--stderrthreshold": true, "--field-selector": true,
+	"--sort-by": true, "--template": true, "--type": true,
+	"--timeout": true, "--grace-period": true, "--since": true,
+	"--since-time": true, "--tail": true, "--image": true,
+	"--replicas": true, "--for": true, "--cache-dir": true,
+	"--request-timeout": true, "--subresource": true,
+}
+
+// resourceAliases maps short and singular names to the canonical resource type.
+var resourceAliases = map[string]string{
+	"po": "pods", "pod": "pods",
+	"svc": "services", "service": "services",
+	"deploy": "deployments", "deployment": "deployments",
+	"rs": "replicasets", "replicaset": "replicasets",
+	"sts": "statefulsets", "statefulset": "statefulsets",
+	"ds": "daemonsets", "daemonset": "daemonsets",
+	"cm": "configmaps", "configmap": "configmaps",
+	"secret": "secrets",
+	"ns":     "namespaces", "namespace": "namespaces",
+	"no": "nodes", "node": "nodes",
+	"pvc": "persistentvolumeclaims", "persistentvolumeclaim": "persistentvolumeclaims",
+	"pv": "persistentvolumes", "persistentvolume": "persistentvolumes",
+	"sa": "serviceaccounts", "serviceaccount": "serviceaccounts",
+	"ing": "ingresses", "ingress": "ingresses",
+	"job": "jobs",
+	"cj":  "cronjobs", "cronjob": "cronjobs",
+	"ep": "endpoints",
+	"ev": "events", "event": "events",
+	"hpa": "horizontalpodautoscalers", "horizontalpodautoscaler": "horizontalpodautoscalers",
+	"netpol": "networkpolicies", "networkpolicy": "networkpolicies",
+	"role": "roles", "rolebinding": "rolebindings",
+	"clusterrole": "clusterroles", "clusterrolebinding": "clusterrolebindings",
+	"crd": "customresourcedefinitions", "crds": "customresourcedefinitions",
+	"customresourcedefinition": "customresourcedefinitions",
+	"sc":                       "storageclasses", "storageclass": "storageclasses",
+	"pdb": "poddisruptionbudgets", "poddisruptionbudget": "poddisruptionbudgets",
+	"limits": "limitranges", "limitrange": "limitranges",
+	"quota": "resourcequotas", "resourcequota": "resourcequotas",
+	"rc": "replicationcontrollers", "replicationcontroller": "replicationcontrollers",
+	"cs": "componentstatuses", "componentstatus": "componentstatuses",
+}
+
+// boolShortFlags are the short flags without value, which may be combined,
+// e.g. -it.
+var boolShortFlags = map[string]bool{
+	"-A": true, "-i": true, "-t": true, "-q": true, "-w": true, "-R": true, "-h": true,
+}
+
+// splitShortFlags splits combined short flags, e.g. -it. The first flag taking
+// a value takes the rest of the argument, e.g. -nprod. It returns false if arg
+// is not made of known short flags, e.g. -wide.
+func splitShortFlags(arg string, positional []string) (names []string, value string, ok bool) {
+	if strings.HasPrefix(arg, "--") || len(arg) <= 2 || arg[2] == '=' {
+		return nil, "", false
+	}
+	for j := 1; j < len(arg); j++ {
+		name := "-" + arg[j:j+1]
+		switch {
+		case valueFlags[name] || isFilenameFlag(name, positional):
+			return append(names, name), arg[j+1:], true
+		case boolShortFlags[name] || name == "-f":
+			names = append(names, name)
+		default:
+			return nil, "", false
+		}
+	}
+	return names, "", true
+}
+
+// isFilenameFlag reports whether -f is --filename rather than --follow of logs.
+func isFilenameFlag(name string, positional []string) bool {
+	return name == "-f" && (len(positional) == 0 || positional[0] != "logs")
+}
+
+// normalizeResource returns the canonical type of a resource, e.g. pods for
+// po, pod and pods.apps.
+func normalizeResource(r string) string {
+	r = strings.ToLower(r)
+	if i := strings.Index(r, "."); i > 0 {
+		r = r[:i]
+	}
+	if canonical, ok := resourceAliases[r]; ok {
+		return canonical
+	}
+	return r
+}
+
+func (cmd *command) setFlag(name, value string) {
+	cmd.Flags[name] = value
+
+	switch name {
+	case "-n", "--namespace":
+		cmd.Namespace = value
+	case "-A", "--all-namespaces":
+		cmd.AllNamespaces = value == "" || value == "true"
+	}
+}
+
+// parseCommand parses the argv of a kubectl command, e.g. from parsePipeline.
+func parseCommand(argv []string) *command {
+	cmd := &command{Flags: map[string]string{}}
+
+	var positional []string
+	// unknown is an unknown flag before the verb, which may take the next
+	// argument as value.
+	unknown := ""
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		if arg == "--" {
+			// arguments of the command run by exec or run
+			cmd.Flags["--"] = strings.Join(argv[i+1:], " ")
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if unknown != "" && !verbs[arg] {
+				// a value rather than the verb, e.g. 5 of --verbosity 5,
+				// or the policy would match the wrong verb.
+				cmd.setFlag(unknown, arg)
+				unknown = ""
+				continue
+			}
+			positional = append(positional, arg)
+			unknown = ""
+			continue
+		}
+		unknown = ""
+
+		if names, value, ok := splitShortFlags(arg, positional); ok {
+			last := names[len(names)-1]
+			if (valueFlags[last] || isFilenameFlag(last, positional)) && value == "" && i+1 < len(argv) {
+				i++
+				value = argv[i]
+			}
+			for _, name := range names[:len(names)-1] {
+				cmd.setFlag(name, "")
+			}
+			cmd.setFlag(last, value)
+			continue
+		}
+
+		name, value := arg, ""
+		if j := strings.Index(arg, "="); j > 0 {
+			name, value = arg[:j], arg[j+1:]
+		} else if (valueFlags[name] || isFilenameFlag(name, positional)) && i+1 < len(argv) {
+			i++
+			value = argv[i]
+		} else if len(positional) == 0 {
+			unknown = name
+		}
+		cmd.setFlag(name, value)
+	}
+
+	if len(positional) == 0 {
+		return cmd
+	}
+	cmd.Verb, positional = positional[0], positional[1:]
+	if groupVerbs[cmd.Verb] && len(positional) > 0 {
+		cmd.Verb, positional = cmd.Verb+" "+positional[0], positional[1:]
+	}
+
+	switch strings.Split(cmd.Verb, " ")[0] {
+	case "logs", "exec", "attach", "port-forward", "cp":
+		// pods unless given as type/name
+		cmd.Resources = []string{"pods"}
+		if len(positional) > 0 && strings.Contains(positional[0], "/") {
+			cmd.Resources = []string{normalizeResource(strings.SplitN(positional[0], "/", 2)[0])}
+		}
+		cmd.Args = positional
+	case "cordon", "uncordon", "drain", "taint":
+		cmd.Resources = []string{"nodes"}
+		cmd.Args = positional
+	case "get", "describe", "delete", "edit", "patch", "label", "annotate",
+		"scale", "autoscale", "expose", "rollout", "set", "explain", "create",
+		"top", "wait", "replace":
+		if len(positional) == 0 {
+			return cmd
+		}
+		// resource types are given as pods, pods,svc or pods/name
+		if strings.Contains(positional[0], "/") {
+			for _, arg := range positional {
+				if strings.Contains(arg, "/") {
+					cmd.Resources = append(cmd.Resources, normalizeResource(strings.SplitN(arg, "/", 2)[0]))
+				}
+			}
+			cmd.Args = positional
+			return cmd
+		}
+		for _, r := range strings.Split(positional[0], ",") {
+			cmd.Resources = append(cmd.Resources, normalizeResource(r))
+		}
+		cmd.Args = positional[1:]
+	default:
+		cmd.Args = positional
+	}
+	return cmd
+}
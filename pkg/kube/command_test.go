@@ -0,0 +1,97 @@
+package kube
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	scenarioTable := []struct {
+		input    string
+		expected *command
+	}{
+		{
+			input: "get po -n kube-system",
+			expected: &command{
+				Verb: "get", Resources: []string{"pods"}, Args: []string{},
+				Namespace: "kube-system", Flags: map[string]string{"-n": "kube-system"},
+			},
+		},
+		{
+			input: "get deploy,svc.v1 web -A",
+			expected: &command{
+				Verb: "get", Resources: []string{"deployments", "services"}, Args: []string{"web"},
+				AllNamespaces: true, Flags: map[string]string{"-A": ""},
+			},
+		},
+		{
+			input: "delete secret/db-creds --namespace=payments",
+			expected: &command{
+				Verb: "delete", Resources: []string{"secrets"}, Args: []string{"secret/db-creds"},
+				Namespace: "payments", Flags: map[string]string{"--namespace": "payments"},
+			},
+		},
+		{
+			input: "config view --raw",
+			expected: &command{
+				Verb: "config view", Args: []string{}, Flags: map[string]string{"--raw": ""},
+			},
+		},
+		{
+			input: "logs -f web-0",
+			expected: &command{
+				Verb: "logs", Resources: []string{"pods"}, Args: []string{"web-0"},
+				Flags: map[string]string{"-f": ""},
+			},
+		},
+		{
+			input: "apply -f deploy.yaml",
+			expected: &command{
+				Verb: "apply", Args: []string{}, Flags: map[string]string{"-f": "deploy.yaml"},
+			},
+		},
+		{
+			input: "exec -it web-0 -- rm -rf /",
+			expected: &command{
+				Verb: "exec", Resources: []string{"pods"}, Args: []string{"web-0"},
+				Flags: map[string]string{"-i": "", "-t": "", "--": "rm -rf /"},
+			},
+		},
+		{
+			input: "exec -itnprod -cweb web-0",
+			expected: &command{
+				Verb: "exec", Resources: []string{"pods"}, Args: []string{"web-0"},
+				Namespace: "prod", Flags: map[string]string{"-i": "", "-t": "", "-n": "prod", "-c": "web"},
+			},
+		},
+		{
+			input: "set image deployment/web nginx=nginx:1.21",
+			expected: &command{
+				Verb: "set image", Resources: []string{"deployments"}, Args: []string{"deployment/web", "nginx=nginx:1.21"},
+				Flags: map[string]string{},
+			},
+		},
+		{
+			input: "--context prod --verbosity 5 get po",
+			expected: &command{
+				Verb: "get", Resources: []string{"pods"}, Args: []string{},
+				Flags: map[string]string{"--context": "prod", "--verbosity": "5"},
+			},
+		},
+		{
+			input: "drain node-1 --force",
+			expected: &command{
+				Verb: "drain", Resources: []string{"nodes"}, Args: []string{"node-1"},
+				Flags: map[string]string{"--force": ""},
+			},
+		},
+	}
+
+	for _, s := range scenarioTable {
+		actual := parseCommand(strings.Fields(s.input))
+		if !reflect.DeepEqual(actual, s.expected) {
+			t.Errorf("%q: should be %#v, got %#v", s.input, s.expected, actual)
+		}
+	}
+}
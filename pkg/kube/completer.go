@@ -4,18 +4,56 @@ import (
 	"context"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/paralus/prompt/pkg/prompt"
 	"github.com/paralus/prompt/pkg/prompt/completer"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// CompleterOption is the type to replace default parameters of the completer.
+type CompleterOption func(c *Completer)
+
+// OptionCluster to set the name of the cluster, the resource types discovered
+// are shared with other completers of the cluster.
+func OptionCluster(name string) CompleterOption {
+	return func(c *Completer) {
+		c.cluster = name
+	}
+}
+
+// OptionWatchCache to keep suggestions of up to maxTypes resource types up to
+// date with informers instead of listing them on completion, 0 disables it.
+func OptionWatchCache(maxTypes int) CompleterOption {
+	return func(c *Completer) {
+		c.watchTypes = maxTypes
+	}
+}
+
+// OptionCompleteAliases to suggest the aliases of the user and complete them
+// as the commands they expand to.
+func OptionCompleteAliases(a *Aliases) CompleterOption {
+	return func(c *Completer) {
+		c.aliases = a
+	}
+}
+
+// OptionClusterNames to complete the clusters of use-cluster with the names
+// returned by fn, which must not block.
+func OptionClusterNames(fn func() []string) CompleterOption {
+	return func(c *Completer) {
+		c.clusterNames = fn
+	}
+}
+
 // NewCompleter returns new prompt completer for kubeconfig file
-func NewCompleter(ctx context.Context, kubeConfig []byte) (*Completer, error) {
+func NewCompleter(ctx context.Context, kubeConfig []byte, opts ...CompleterOption) (*Completer, error) {
 	clientConfig, err := clientcmd.NewClientConfigFromBytes(kubeConfig)
 	if err != nil {
 		return nil, err
@@ -37,6 +75,11 @@ func NewCompleter(ctx context.Context, kubeConfig []byte) (*Completer, error) {
 		return nil, err
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	// TODO handle namespace for restricted users
 	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
@@ -47,18 +90,85 @@ func NewCompleter(ctx context.Context, kubeConfig []byte) (*Completer, error) {
 		}
 	}
 
-	return &Completer{
+	c := &Completer{
+		cache:         newResourceCache(),
+		host:          config.Host,
 		namespace:     namespace,
 		namespaceList: namespaces,
 		client:        client,
-	}, nil
+		dynamic:       dynamicClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	if c.watchTypes > 0 {
+		md, err := metadata.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		c.watches = newWatchCache(client, md, c.watchTypes)
+		// pods are completed by most commands, start watching them
+		c.watches.pods(namespace)
+	}
+
+	// discover resource types at session start for faster first completion
+	c.resourceCatalog()
+	return c, nil
 }
 
 // Completer is prompt completer
 type Completer struct {
+	host          string
+	cluster       string
+	m             sync.RWMutex
 	namespace     string
 	namespaceList *corev1.NamespaceList
 	client        *kubernetes.Clientset
+	dynamic       dynamic.Interface
+	cache         *resourceCache
+	watchTypes    int
+	watches       *watchCache
+	aliases       *Aliases
+	clusterNames  func() []string
+
+	// ctx is canceled when the completer is closed, aborting pending lists
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Close releases the cached resources, the completer must not be used afterwards.
+func (c *Completer) Close() {
+	c.cancel()
+	c.cache.close()
+	if c.watches != nil {
+		c.watches.close()
+	}
+}
+
+// resourceCatalog returns the resource types discovered on the cluster, nil
+// until discovery finished.
+func (c *Completer) resourceCatalog() *resourceCatalog {
+	return discoverResources(c.host+"/"+c.cluster, c.client.Discovery())
+}
+
+// Namespace returns the namespace resources are completed in, the namespace of
+// the kubeconfig context until it is changed with SetNamespace.
+func (c *Completer) Namespace() string {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	return c.namespace
+}
+
+// SetNamespace changes the namespace resources are completed in.
+func (c *Completer) SetNamespace(ns string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.namespace = ns
+	if c.watches != nil {
+		c.watches.pods(ns)
+	}
 }
 
 // Complete completes the prompt input
@@ -66,6 +176,7 @@ func (c *Completer) Complete(d prompt.Document) []prompt.Suggest {
 	if d.TextBeforeCursor() == "" {
 		return []prompt.Suggest{}
 	}
+	d = c.expandAlias(fanoutCommand(d))
 	args := strings.Split(d.TextBeforeCursor(), " ")
 	w := d.GetWordBeforeCursor()
 
@@ -88,7 +199,7 @@ func (c *Completer) Complete(d prompt.Document) []prompt.Suggest {
 
 	namespace := checkNamespaceArg(d)
 	if namespace == "" {
-		namespace = c.namespace
+		namespace = c.Namespace()
 	}
 	commandArgs, skipNext := excludeOptions(args)
 	if skipNext {
@@ -99,6 +210,41 @@ func (c *Completer) Complete(d prompt.Document) []prompt.Suggest {
 	return c.argumentsCompleter(namespace, commandArgs)
 }
 
+// expandAlias returns the document with an alias in the first word replaced
+// by its command, once the alias is followed by a space.
+func (c *Completer) expandAlias(d prompt.Document) prompt.Document {
+	if c.aliases == nil {
+		return d
+	}
+	before := d.TextBeforeCursor()
+	i := strings.Index(before, " ")
+	if i < 0 {
+		return d
+	}
+	command, ok := c.aliases.Get(before[:i])
+	if !ok {
+		return d
+	}
+
+	b := prompt.NewBuffer()
+	b.InsertText(command+before[i:], false, true)
+	b.InsertText(d.TextAfterCursor(), false, false)
+	return *b.Document()
+}
+
+// aliasSuggestions returns the aliases of the user.
+func (c *Completer) aliasSuggestions() []prompt.Suggest {
+	if c.aliases == nil {
+		return nil
+	}
+	var suggestions []prompt.Suggest
+	for _, name := range c.aliases.Names() {
+		command, _ := c.aliases.Get(name)
+		suggestions = append(suggestions, prompt.Suggest{Text: name, Description: "alias: " + command})
+	}
+	return suggestions
+}
+
 func checkNamespaceArg(d prompt.Document) string {
 	args := strings.Split(d.Text, " ")
 	var found bool
@@ -167,9 +313,9 @@ func (c *Completer) completeOptionArguments(d prompt.Document) ([]prompt.Suggest
 			cmdArgs := getCommandArgs(d)
 			var suggestions []prompt.Suggest
 			if cmdArgs == nil || len(cmdArgs) < 2 {
-				suggestions = getContainerNamesFromCachedPods(c.client, c.namespace)
+				suggestions = c.getContainerNamesFromCachedPods(c.Namespace())
 			} else {
-				suggestions = getContainerName(c.client, c.namespace, cmdArgs[1])
+				suggestions = c.getContainerName(c.Namespace(), cmdArgs[1])
 			}
 			return prompt.FilterHasPrefix(
 				suggestions,
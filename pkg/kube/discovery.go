@@ -0,0 +1,209 @@
+package kube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	prompt "github.com/paralus/prompt/pkg/prompt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// discoveryRefreshInterval is how long the discovered resource types of a
+// cluster are used before they are discovered again, e.g. for new CRDs.
+const discoveryRefreshInterval = 10 * time.Minute
+
+// apiResource is a resource type served by the cluster.
+type apiResource struct {
+	gvr        schema.GroupVersionResource
+	kind       string
+	singular   string
+	shortNames []string
+	namespaced bool
+	listable   bool
+	watchable  bool
+	// text is the name to complete, qualified with the group if another
+	// group serves a resource of the same name.
+	text string
+}
+
+func (r *apiResource) description() string {
+	gv := r.gvr.GroupVersion().String()
+	if len(r.shortNames) == 0 {
+		return fmt.Sprintf("%s %s", r.kind, gv)
+	}
+	return fmt.Sprintf("%s (%s) %s", r.kind, strings.Join(r.shortNames, ","), gv)
+}
+
+// resourceCatalog holds the resource types discovered on a cluster.
+type resourceCatalog struct {
+	resources []*apiResource
+	// byName maps plural, singular, short and kind names as well as names
+	// qualified with the group to the resource.
+	byName map[string]*apiResource
+}
+
+func newResourceCatalog(lists []*metav1.APIResourceList) *resourceCatalog {
+	rc := &resourceCatalog{byName: map[string]*apiResource{}}
+
+	// the core group wins if several groups serve a resource of the same
+	// name, as it does for kubectl.
+	sorted := make([]*metav1.APIResourceList, 0, len(lists))
+	for _, l := range lists {
+		if l != nil {
+			sorted = append(sorted, l)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GroupVersion == "v1" && sorted[j].GroupVersion != "v1"
+	})
+
+	for _, l := range sorted {
+		gv, err := schema.ParseGroupVersion(l.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, ar := range l.APIResources {
+			// subresources, e.g. pods/log
+			if strings.Contains(ar.Name, "/") {
+				continue
+			}
+			r := &apiResource{
+				gvr:        gv.WithResource(ar.Name),
+				kind:       ar.Kind,
+				singular:   ar.SingularName,
+				shortNames: ar.ShortNames,
+				namespaced: ar.Namespaced,
+				listable:   contains(ar.Verbs, "list"),
+				watchable:  contains(ar.Verbs, "watch"),
+				text:       ar.Name,
+			}
+			if _, ok := rc.byName[ar.Name]; ok && gv.Group != "" {
+				r.text = ar.Name + "." + gv.Group
+			}
+			rc.resources = append(rc.resources, r)
+
+			names := append([]string{ar.Name, ar.SingularName, strings.ToLower(ar.Kind)}, ar.ShortNames...)
+			for _, name := range names {
+				if name == "" {
+					continue
+				}
+				if _, ok := rc.byName[name]; !ok {
+					rc.byName[name] = r
+				}
+				if gv.Group != "" {
+					rc.byName[name+"."+gv.Group] = r
+					rc.byName[name+"."+gv.Version+"."+gv.Group] = r
+				}
+			}
+		}
+	}
+
+	sort.Slice(rc.resources, func(i, j int) bool {
+		return rc.resources[i].text < rc.resources[j].text
+	})
+	return rc
+}
+
+// lookup returns the resource type for a name as accepted by kubectl, e.g.
+// po, pod, pods, deployments.apps or deployments.v1.apps.
+func (rc *resourceCatalog) lookup(name string) (*apiResource, bool) {
+	r, ok := rc.byName[strings.ToLower(name)]
+	return r, ok
+}
+
+// suggestions returns the resource types whose name or short names start with prefix.
+func (rc *resourceCatalog) suggestions(prefix string) []prompt.Suggest {
+	prefix = strings.ToLower(prefix)
+	var s []prompt.Suggest
+	for _, r := range rc.resources {
+		match := strings.HasPrefix(r.text, prefix)
+		for _, sn := range r.shortNames {
+			match = match || strings.HasPrefix(sn, prefix)
+		}
+		if match {
+			s = append(s, prompt.Suggest{Text: r.text, Description: r.description()})
+		}
+	}
+	return s
+}
+
+// clusterDiscovery is the discovered resource types of a cluster, shared by
+// all sessions of the cluster.
+type clusterDiscovery struct {
+	m         sync.Mutex
+	catalog   *resourceCatalog
+	nextFetch time.Time
+	fetching  bool
+}
+
+var discoveryCache sync.Map
+
+// discoverResources returns the cached resource types of the cluster and
+// rediscovers them in the background once they are stale. It returns nil
+// until the first discovery finished.
+func discoverResources(cluster string, client discovery.DiscoveryInterface) *resourceCatalog {
+	v, _ := discoveryCache.LoadOrStore(cluster, &clusterDiscovery{})
+	cd := v.(*clusterDiscovery)
+
+	cd.m.Lock()
+	defer cd.m.Unlock()
+	if !cd.fetching && time.Now().After(cd.nextFetch) {
+		cd.fetching = true
+		go cd.fetch(cluster, client)
+	}
+	return cd.catalog
+}
+
+func (cd *clusterDiscovery) fetch(cluster string, client discovery.DiscoveryInterface) {
+	// discovery returns the resources of all available groups along with
+	// an error for groups which failed, e.g. an unavailable metrics server.
+	lists, err := client.ServerPreferredResources()
+	if err != nil {
+		_log.Infow("unable to discover all resources", "cluster", cluster, "error", err)
+	}
+
+	cd.m.Lock()
+	defer cd.m.Unlock()
+	cd.fetching = false
+	if len(lists) == 0 {
+		cd.nextFetch = time.Now().Add(thresholdFetchInterval)
+		return
+	}
+	cd.catalog = newResourceCatalog(lists)
+	cd.nextFetch = time.Now().Add(discoveryRefreshInterval)
+}
+
+// maxResourceNames limits the objects listed for name completion.
+const maxResourceNames = 500
+
+// getResourceNameSuggestions returns the names of objects of any listable
+// resource type, including custom resources.
+func (c *Completer) getResourceNameSuggestions(r *apiResource, namespace string) []prompt.Suggest {
+	if !r.listable {
+		return []prompt.Suggest{}
+	}
+	if !r.namespaced {
+		namespace = ""
+	}
+	if c.watches != nil && r.watchable {
+		if names, ok := c.watches.names(r.gvr, namespace); ok {
+			return nameSuggestions(names)
+		}
+	}
+	return c.cachedSuggestions(r.gvr.GroupResource().String(), namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.dynamic.Resource(r.gvr).Namespace(namespace).List(c.ctx, metav1.ListOptions{Limit: maxResourceNames})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].GetName()
+		}
+		return nameSuggestions(names), nil
+	})
+}
@@ -0,0 +1,144 @@
+package kube
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	prompt "github.com/paralus/prompt/pkg/prompt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var testResourceLists = []*metav1.APIResourceList{
+	{
+		GroupVersion: "events.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "events", SingularName: "event", Kind: "Event", Namespaced: true, ShortNames: []string{"ev"}, Verbs: []string{"list"}},
+		},
+	},
+	{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", SingularName: "pod", Kind: "Pod", Namespaced: true, ShortNames: []string{"po"}, Verbs: []string{"get", "list"}},
+			{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: []string{"get"}},
+			{Name: "events", SingularName: "event", Kind: "Event", Namespaced: true, ShortNames: []string{"ev"}, Verbs: []string{"list"}},
+			{Name: "bindings", SingularName: "binding", Kind: "Binding", Namespaced: true, Verbs: []string{"create"}},
+		},
+	},
+	{
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "statefulsets", SingularName: "statefulset", Kind: "StatefulSet", Namespaced: true, ShortNames: []string{"sts"}, Verbs: []string{"list"}},
+		},
+	},
+	{
+		GroupVersion: "cert-manager.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "certificates", SingularName: "certificate", Kind: "Certificate", Namespaced: true, ShortNames: []string{"cert", "certs"}, Verbs: []string{"list"}},
+		},
+	},
+}
+
+func TestResourceCatalogLookup(t *testing.T) {
+	rc := newResourceCatalog(testResourceLists)
+
+	scenarioTable := []struct {
+		name     string
+		expected string
+		found    bool
+	}{
+		{name: "po", expected: "/v1, Resource=pods", found: true},
+		{name: "Pod", expected: "/v1, Resource=pods", found: true},
+		{name: "events", expected: "/v1, Resource=events", found: true},
+		{name: "events.events.k8s.io", expected: "events.k8s.io/v1, Resource=events", found: true},
+		{name: "sts", expected: "apps/v1, Resource=statefulsets", found: true},
+		{name: "statefulsets.v1.apps", expected: "apps/v1, Resource=statefulsets", found: true},
+		{name: "cert", expected: "cert-manager.io/v1, Resource=certificates", found: true},
+		{name: "pods/log"},
+		{name: "thirdpartyresources"},
+	}
+
+	for _, s := range scenarioTable {
+		r, ok := rc.lookup(s.name)
+		if ok != s.found {
+			t.Errorf("%q: should be found %t, got %t", s.name, s.found, ok)
+			continue
+		}
+		if ok && r.gvr.String() != s.expected {
+			t.Errorf("%q: should be %s, got %s", s.name, s.expected, r.gvr.String())
+		}
+	}
+}
+
+func TestResourceCatalogSuggestions(t *testing.T) {
+	rc := newResourceCatalog(testResourceLists)
+
+	scenarioTable := []struct {
+		prefix   string
+		expected []prompt.Suggest
+	}{
+		{
+			prefix: "ev",
+			expected: []prompt.Suggest{
+				{Text: "events", Description: "Event (ev) v1"},
+				{Text: "events.events.k8s.io", Description: "Event (ev) events.k8s.io/v1"},
+			},
+		},
+		{
+			prefix:   "sts",
+			expected: []prompt.Suggest{{Text: "statefulsets", Description: "StatefulSet (sts) apps/v1"}},
+		},
+		{
+			prefix: "b",
+			expected: []prompt.Suggest{
+				{Text: "bindings", Description: "Binding v1"},
+			},
+		},
+	}
+
+	for _, s := range scenarioTable {
+		actual := rc.suggestions(s.prefix)
+		if !reflect.DeepEqual(actual, s.expected) {
+			t.Errorf("%q: should be %#v, got %#v", s.prefix, s.expected, actual)
+		}
+	}
+}
+
+func TestResourceNameSuggestions(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+	cert := func(namespace, name string) runtime.Object {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("cert-manager.io/v1")
+		u.SetKind("Certificate")
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "CertificateList"},
+		cert("payments", "api-tls"), cert("payments", "web-tls"), cert("default", "other-tls"),
+	)
+	c := &Completer{ctx: context.Background(), cache: newResourceCache(), dynamic: client}
+	r, _ := newResourceCatalog(testResourceLists).lookup("certs")
+
+	// names are listed in the background on first completion
+	var actual []prompt.Suggest
+	for i := 0; i < 100 && len(actual) == 0; i++ {
+		actual = c.getResourceNameSuggestions(r, "payments")
+		time.Sleep(10 * time.Millisecond)
+	}
+	expected := []prompt.Suggest{{Text: "api-tls"}, {Text: "web-tls"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("should be %#v, got %#v", expected, actual)
+	}
+
+	binding, _ := newResourceCatalog(testResourceLists).lookup("bindings")
+	if s := c.getResourceNameSuggestions(binding, "payments"); len(s) != 0 {
+		t.Errorf("should not list resources without list verb, got %#v", s)
+	}
+}
@@ -1,16 +1,17 @@
 package kube
 
 import (
-	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/creack/pty"
-	"github.com/mattn/go-shellwords"
 	"github.com/paralus/paralus/pkg/audit"
 	logv2 "github.com/paralus/paralus/pkg/log"
 	"github.com/paralus/prompt/pkg/prompt"
@@ -19,124 +20,362 @@ import (
 
 var _log = logv2.GetLogger()
 
-func isInteractive(s string) bool {
-	switch {
-	case strings.Index(s, "exec") >= 0:
-	case strings.Index(s, "logs") >= 0:
-	case strings.Index(s, "edit") >= 0:
-	case strings.Index(s, "-w") >= 0:
-	default:
-		return false
+type ioExecutor struct {
+	rw              io.ReadWriter
+	term            *Terminal
+	args            []string
+	event           *audit.Event
+	kubectlBin      string
+	auditLogger     *zap.Logger
+	pipelineTimeout time.Duration
+	policy          *Policy
+	namespace       string
+	// defaultNamespace is the namespace of the kubeconfig, the namespace is
+	// only passed to kubectl once it is changed.
+	defaultNamespace  string
+	previousNamespace string
+	onNamespaceChange func(ns string)
+	outputLimit       int64
+	interactive       []InteractiveRule
+	aliases           *Aliases
+	clusters          ClusterSwitcher
+	fanout            ClusterFanout
+	fanoutWorkers     int
+}
+
+// ExecutorOption is the type to replace default parameters of the executor.
+type ExecutorOption func(e *ioExecutor)
+
+// OptionPipelineTimeout to set the maximum run time of a command piped to filters.
+func OptionPipelineTimeout(d time.Duration) ExecutorOption {
+	return func(e *ioExecutor) {
+		if d > 0 {
+			e.pipelineTimeout = d
+		}
+	}
+}
+
+// OptionPolicy to set the policy commands are checked against before running them.
+func OptionPolicy(p *Policy) ExecutorOption {
+	return func(e *ioExecutor) {
+		if p != nil {
+			e.policy = p
+		}
 	}
+}
 
-	return true
+// OptionDefaultNamespace to set the namespace of commands without namespace flag.
+func OptionDefaultNamespace(ns string) ExecutorOption {
+	return func(e *ioExecutor) {
+		e.namespace = ns
+		e.defaultNamespace = ns
+	}
 }
 
-// NewIOExecutor returns executor tied to io ReadWriter
-func NewIOExecutor(rw io.ReadWriter, rows, cols uint16, args []string, event *audit.Event, kubectlBin string, auditLogger *zap.Logger) prompt.Executor {
-	return func(ctx context.Context, s string) {
-		s = strings.Trim(s, " ")
-		if s == "" {
-			return
+// OptionOnNamespaceChange to set the function called when the namespace of the
+// session is changed, e.g. by use-namespace.
+func OptionOnNamespaceChange(fn func(ns string)) ExecutorOption {
+	return func(e *ioExecutor) {
+		e.onNamespaceChange = fn
+	}
+}
+
+// OptionOutputLimit to set the number of bytes of output shown per command.
+func OptionOutputLimit(n int64) ExecutorOption {
+	return func(e *ioExecutor) {
+		if n > 0 {
+			e.outputLimit = n
 		}
+	}
+}
 
-		createKubectlCommandAudit(event, "kubectl "+s, auditLogger)
+// OptionInteractiveRules to set the rules for commands run attached to a pseudo terminal.
+func OptionInteractiveRules(rules []InteractiveRule) ExecutorOption {
+	return func(e *ioExecutor) {
+		if len(rules) > 0 {
+			e.interactive = rules
+		}
+	}
+}
 
-		// handle prompt clear
-		if strings.Index(s, "clear") >= 0 {
-			// clear | hexdump
-			rw.Write([]byte{0x1b, 0x5b, 0x48, 0x1b, 0x5b, 0x32, 0x4a})
-			return
+// OptionAliases to set the aliases of the user, which are expanded before running commands.
+func OptionAliases(a *Aliases) ExecutorOption {
+	return func(e *ioExecutor) {
+		if a != nil {
+			e.aliases = a
 		}
+	}
+}
 
-		var execArgs []string
+// NewIOExecutor returns executor tied to io ReadWriter
+func NewIOExecutor(rw io.ReadWriter, term *Terminal, args []string, event *audit.Event, kubectlBin string, auditLogger *zap.Logger, opts ...ExecutorOption) prompt.Executor {
+	e := &ioExecutor{
+		rw:              rw,
+		term:            term,
+		event:           event,
+		kubectlBin:      kubectlBin,
+		auditLogger:     auditLogger,
+		pipelineTimeout: defaultPipelineTimeout,
+		policy:          DefaultPolicy(),
+		outputLimit:     DefaultOutputLimit,
+		interactive:     DefaultInteractiveRules(),
+		fanoutWorkers:   DefaultFanoutWorkers,
+	}
+	e.aliases, _ = LoadAliases("")
+
+	// appending default flags
+	e.setArgs(args)
+
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e.execute
+}
+
+func (e *ioExecutor) execute(ctx context.Context, s string) {
+	rw := e.rw
+	s = strings.Trim(s, " ")
+	if s == "" {
+		return
+	}
+
+	if e.runBuiltin(ctx, s) {
+		return
+	}
+	if expanded, ok := e.aliases.Expand(s); ok {
+		_log.Debugw("expanded alias", "input", s, "command", expanded)
+		s = expanded
+	}
+
+	// handle prompt clear
+	if strings.Index(s, "clear") >= 0 {
+		createKubectlCommandAudit(e.event, "kubectl "+s, nil, e.auditLogger)
+		// clear | hexdump
+		rw.Write([]byte{0x1b, 0x5b, 0x48, 0x1b, 0x5b, 0x32, 0x4a})
+		return
+	}
+
+	// splitting kubectl command from the filters it is piped to
+	pl, err := parsePipeline(s)
+	if err != nil {
+		createKubectlCommandAudit(e.event, "kubectl "+s, nil, e.auditLogger)
+		_log.Infow("unable to parse command", "error", err)
+		e.writeError(err)
+		return
+	}
+
+	parsed := parseCommand(pl.kubectl)
+	if !e.checkPolicy(s, pl) {
+		observeCommand(parsed, commandDenied, 0)
+		return
+	}
 
-		// appending kubectl commands to execute
-		p, err := shellwords.Parse(s)
+	defaultArgs := e.defaultArgs(parsed)
+	start := time.Now()
+
+	var execArgs []string
+
+	// appending kubectl commands to execute
+	execArgs = append(execArgs, pl.kubectl...)
+
+	// appending default flags
+	execArgs = append(execArgs, defaultArgs...)
+
+	if len(pl.filters) == 0 && isInteractive(parsed, e.interactive) {
+		_log.Debugw("executing interactive kubectl", "args", s)
+
+		cmd := exec.CommandContext(ctx, e.kubectlBin, execArgs...)
+		cmd.Env = append(cmd.Env, os.Environ()...)
+		cmd.Env = append(cmd.Env, "KUBE_EDITOR=vim")
+
+		f, err := pty.StartWithSize(cmd, e.term.Size())
 		if err != nil {
-			_log.Error("unable to parse command", zap.Error(err))
+			observeCommand(parsed, commandFailed, time.Since(start))
+			rw.Write([]byte(err.Error()))
+			rw.Write([]byte{'\r', '\n'})
 			return
 		}
-		execArgs = append(execArgs, p...)
+		e.term.attach(f)
 
-		// appending default flags
-		for _, arg := range args {
-			if strings.TrimSpace(arg) != "" {
-				execArgs = append(execArgs, arg)
-			}
-		}
+		// input is read until the command exits, the following input is
+		// left to the prompt
+		ictx, stopInput := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		wg.Add(2)
 
-		if isInteractive(s) {
-			_log.Debugw("executing interactive kubectl", "args", s)
+		go func() {
+			defer wg.Done()
+			_, err := io.Copy(rw, f)
+			_log.Infow("exited copy from pty", "error", err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := io.Copy(f, readerWithContext(ictx, rw))
+			_log.Infow("exited copy to pty", "error", err)
+		}()
 
-			cmd := exec.CommandContext(ctx, kubectlBin, execArgs...)
-			cmd.Env = append(cmd.Env, os.Environ()...)
-			cmd.Env = append(cmd.Env, "KUBE_EDITOR=vim")
+		err = cmd.Wait()
+		stopInput()
+		e.term.detach()
+		f.Close()
+		wg.Wait()
+		observeCommand(parsed, commandStatus(ctx, err), time.Since(start))
+		return
+	}
 
-			f, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: rows, Cols: cols})
-			if err != nil {
-				rw.Write([]byte(err.Error()))
-				rw.Write([]byte{'\r', '\n'})
-				return
-			}
+	_log.Debugw("executing non interative kubectl", "args", execArgs)
 
-			var wg sync.WaitGroup
-			wg.Add(2)
-
-			go func() {
-				defer wg.Done()
-				_, err := io.Copy(rw, f)
-				_log.Infow("exited copy from pty", "error", err)
-			}()
-			go func() {
-				defer wg.Done()
-				_, err := io.Copy(f, rw)
-				_log.Infow("exited copy to pty", "error", err)
-			}()
-
-			cmd.Wait()
-			f.Close()
-			wg.Wait()
-			return
-		}
+	// output is streamed as it is produced, Ctrl-C or exceeding the output
+	// limit stops the command.
+	cctx, stop := e.watchInterrupt(ctx)
+	defer stop()
+	out := newOutputWriter(rw, e.outputLimit, stop)
 
-		_log.Debugw("executing non interative kubectl", "args", execArgs)
+	if len(pl.filters) > 0 {
+		pctx, cancel := context.WithTimeout(cctx, e.pipelineTimeout)
+		defer cancel()
 
-		if len(execArgs) > 3 && execArgs[0] == "config" {
-			// filter raw/flattern argument to avoid displaying cert data
-			for _, s := range execArgs {
-				if s == "--raw" || s == "--flatten" {
-					return
-				}
-			}
+		err = pl.run(pctx, e.kubectlBin, defaultArgs, out)
+		if err != nil && cctx.Err() == nil {
+			_log.Infow("unable to run pipeline", "error", err)
+			out.Write([]byte(err.Error() + "\n"))
 		}
-
-		cmd := exec.CommandContext(ctx, kubectlBin, execArgs...)
-		out, err := cmd.CombinedOutput()
+	} else {
+		cmd := exec.CommandContext(cctx, e.kubectlBin, execArgs...)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		err = cmd.Run()
 		if err != nil {
 			_log.Infow("unable to run command", "error", err)
 		}
-		_log.Infow("executed non interative kubectl", "args", execArgs)
-		out = bytes.ReplaceAll(out, []byte{'\n'}, []byte{'\r', '\n'})
-		_, err = rw.Write(out)
+	}
+	observeCommand(parsed, commandStatus(cctx, err), time.Since(start))
+	_log.Infow("executed non interative kubectl", "args", execArgs, "filters", pl.filters, "bytes", out.written, "truncated", out.truncated)
+}
+
+// defaultArgs returns the flags appended to the command, the namespace of the
+// session is added unless the command sets the namespace.
+func (e *ioExecutor) defaultArgs(cmd *command) []string {
+	ns := e.namespaceArgs(cmd)
+	if ns == nil {
+		return e.args
+	}
+	return append(append([]string{}, e.args...), ns...)
+}
+
+// namespaceArgs returns the namespace flag of the session, unless it is the
+// namespace of the kubeconfig or the command sets the namespace.
+func (e *ioExecutor) namespaceArgs(cmd *command) []string {
+	if e.namespace == e.defaultNamespace || cmd.Namespace != "" || cmd.AllNamespaces {
+		return nil
+	}
+	return []string{"--namespace", e.namespace}
+}
+
+// checkPolicy evaluates the command against the policy, asking the user for
+// confirmation if required, and audits it with the decision. It returns
+// whether the command may run.
+func (e *ioExecutor) checkPolicy(s string, pl *pipeline) bool {
+	allowed, meta := e.evaluatePolicy(pl)
+	createKubectlCommandAudit(e.event, "kubectl "+s, meta, e.auditLogger)
+	return allowed
+}
+
+// evaluatePolicy evaluates the command against the policy, asking the user
+// for confirmation if required. It returns whether the command may run and
+// the decision as audit meta data.
+func (e *ioExecutor) evaluatePolicy(pl *pipeline) (bool, map[string]string) {
+	cmd := parseCommand(pl.kubectl)
+	if cmd.Namespace == "" && !cmd.AllNamespaces {
+		cmd.Namespace = e.namespace
+	}
+
+	d := e.policy.Evaluate(cmd)
+	meta := d.Meta()
+	allowed := true
+	switch d.Action {
+	case PolicyDeny:
+		allowed = false
+		e.writeError(fmt.Errorf("command blocked by policy: %s", d.Reason))
+	case PolicyConfirm:
+		allowed = e.confirm(fmt.Sprintf("%s, run anyway? [y/N] ", d.Reason))
+		meta["policy_confirmed"] = strconv.FormatBool(allowed)
+	}
+	_log.Infow("evaluated command policy", "args", pl.kubectl, "action", d.Action, "rule", d.Rule, "allowed", allowed)
+	return allowed, meta
+}
+
+// confirm asks the user the question and reads the answer from the terminal.
+func (e *ioExecutor) confirm(question string) bool {
+	e.rw.Write([]byte(question))
+
+	var answer []byte
+	buf := make([]byte, 1024)
+	for {
+		n, err := e.rw.Read(buf)
 		if err != nil {
-			_log.Infow("unable to write output", "error", err)
+			_log.Infow("unable to read confirmation", "error", err)
+			return false
 		}
+		for _, b := range buf[:n] {
+			switch b {
+			case '\r', '\n':
+				e.rw.Write([]byte{'\r', '\n'})
+				a := strings.ToLower(strings.TrimSpace(string(answer)))
+				return a == "y" || a == "yes"
+			case 0x03: // Ctrl-C
+				e.rw.Write([]byte("^C\r\n"))
+				return false
+			case 0x7f, 0x08: // backspace
+				if len(answer) > 0 {
+					answer = answer[:len(answer)-1]
+					e.rw.Write([]byte("\b \b"))
+				}
+			default:
+				if b >= 0x20 && b < 0x7f {
+					answer = append(answer, b)
+					e.rw.Write([]byte{b})
+				}
+			}
+		}
+	}
+}
 
-		return
+// writeError writes the error to the terminal of the user
+func (e *ioExecutor) writeError(err error) {
+	_, werr := e.rw.Write([]byte("error: " + err.Error() + "\r\n"))
+	if werr != nil {
+		_log.Infow("unable to write output", "error", werr)
 	}
 }
 
-// createKubectlCommandAudit send the kubectl command audit event to the audit.log file
-func createKubectlCommandAudit(event *audit.Event, command string, auditLogger *zap.Logger) {
+// createKubectlCommandAudit send the kubectl command audit event to the audit.log file,
+// meta is added to the meta data of the event.
+func createKubectlCommandAudit(event *audit.Event, command string, meta map[string]string, auditLogger *zap.Logger) {
 	if event == nil {
 		_log.Errorw("Event is nil")
 		return
 	}
-	event.Detail.Message = command
-	event.Version = audit.VersionV1
-	event.Category = audit.AuditCategory
-	event.Origin = audit.OriginCluster
 
-	go audit.WriteEvent(event, auditLogger)
+	// the event is written asynchronously, so every command gets its own copy
+	ev := *event
+	var detail audit.EventDetail
+	if event.Detail != nil {
+		detail = *event.Detail
+	}
+	base := detail.Meta
+	detail.Meta = make(map[string]string, len(base)+len(meta))
+	for k, v := range base {
+		detail.Meta[k] = v
+	}
+	for k, v := range meta {
+		detail.Meta[k] = v
+	}
+	detail.Message = command
+	ev.Detail = &detail
+	ev.Version = audit.VersionV1
+	ev.Category = audit.AuditCategory
+	ev.Origin = audit.OriginCluster
+
+	go audit.WriteEvent(&ev, auditLogger)
 }
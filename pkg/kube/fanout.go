@@ -0,0 +1,277 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paralus/prompt/pkg/prompt"
+)
+
+// DefaultFanoutWorkers is the number of clusters a fanout command runs on at
+// the same time.
+const DefaultFanoutWorkers = 4
+
+// fanoutMinOutput is the output kept per cluster when the output limit is
+// shared by many clusters.
+const fanoutMinOutput = 64 << 10
+
+// fanoutVerbs are the read-only commands which may be run on many clusters.
+var fanoutVerbs = map[string]bool{
+	"get":           true,
+	"describe":      true,
+	"top":           true,
+	"version":       true,
+	"api-resources": true,
+	"api-versions":  true,
+	"cluster-info":  true,
+	"explain":       true,
+	"auth can-i":    true,
+}
+
+// ClusterFanout provides the kubeconfigs of the clusters of the user, to run
+// commands on many clusters with fanout.
+type ClusterFanout interface {
+	// Clusters returns the names of the clusters the user can access.
+	Clusters(ctx context.Context) ([]string, error)
+	// ClusterArgs returns the default flags of kubectl for the cluster,
+	// release removes the kubeconfig they refer to.
+	ClusterArgs(ctx context.Context, name string) (args []string, release func(), err error)
+}
+
+// OptionClusterFanout to allow running read-only commands on many clusters with fanout.
+func OptionClusterFanout(f ClusterFanout) ExecutorOption {
+	return func(e *ioExecutor) {
+		e.fanout = f
+	}
+}
+
+// OptionFanoutWorkers to set the number of clusters a fanout command runs on at the same time.
+func OptionFanoutWorkers(n int) ExecutorOption {
+	return func(e *ioExecutor) {
+		if n > 0 {
+			e.fanoutWorkers = n
+		}
+	}
+}
+
+// fanoutResult is the output of the command on a cluster, done is closed once
+// the command ended.
+type fanoutResult struct {
+	cluster string
+	out     bytes.Buffer
+	err     error
+	took    time.Duration
+	done    chan struct{}
+}
+
+// parseFanout splits the arguments of fanout, given as selector -- command.
+func parseFanout(args string) (selector, command string, err error) {
+	selector, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+	if selector == "" || selector == "--" || !(rest == "--" || strings.HasPrefix(rest, "-- ")) {
+		return "", "", errors.New("usage: fanout selector -- command, e.g. fanout 'prod-*' -- get nodes")
+	}
+	command = strings.TrimSpace(strings.TrimPrefix(rest, "--"))
+	if command == "" {
+		return "", "", errors.New("usage: fanout selector -- command, e.g. fanout 'prod-*' -- get nodes")
+	}
+	return strings.Trim(selector, `'"`), command, nil
+}
+
+// matchClusters returns the clusters matching any of the comma separated
+// patterns of the selector, e.g. prod-*,staging.
+func matchClusters(names []string, selector string) ([]string, error) {
+	var matched []string
+	for _, name := range names {
+		for _, pattern := range strings.Split(selector, ",") {
+			ok, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cluster selector %q", selector)
+			}
+			if ok {
+				matched = append(matched, name)
+				break
+			}
+		}
+	}
+	sort.Strings(matched)
+	return matched, nil
+}
+
+// runFanout runs the read-only command on the clusters matching the selector
+// and prints the output grouped by cluster. The clusters and policy decision
+// are added to meta.
+func (e *ioExecutor) runFanout(ctx context.Context, args string, meta map[string]string) {
+	if e.fanout == nil {
+		e.writeError(errors.New("fanout is not enabled"))
+		return
+	}
+
+	selector, s, err := parseFanout(args)
+	if err != nil {
+		e.writeError(err)
+		return
+	}
+	if expanded, ok := e.aliases.Expand(s); ok {
+		s = expanded
+	}
+	pl, err := parsePipeline(s)
+	if err != nil {
+		e.writeError(err)
+		return
+	}
+	parsed := parseCommand(pl.kubectl)
+	if !fanoutVerbs[parsed.Verb] {
+		e.writeError(fmt.Errorf("fanout only runs read-only commands, %q is not allowed", parsed.Verb))
+		return
+	}
+	if isInteractive(parsed, e.interactive) {
+		e.writeError(errors.New("fanout does not run interactive commands"))
+		return
+	}
+
+	allowed, decision := e.evaluatePolicy(pl)
+	for k, v := range decision {
+		meta[k] = v
+	}
+	meta["command"] = s
+	if !allowed {
+		return
+	}
+
+	names, err := e.fanout.Clusters(ctx)
+	if err != nil {
+		_log.Infow("unable to list clusters", "error", err)
+		e.writeError(fmt.Errorf("unable to list clusters: %v", err))
+		return
+	}
+	clusters, err := matchClusters(names, selector)
+	if err != nil {
+		e.writeError(err)
+		return
+	}
+	if len(clusters) == 0 {
+		e.writeError(fmt.Errorf("no cluster matches %s", selector))
+		return
+	}
+	meta["clusters"] = strings.Join(clusters, ",")
+
+	cctx, stop := e.watchInterrupt(ctx)
+	defer stop()
+
+	start := time.Now()
+	results, wait := e.fanoutRun(cctx, clusters, pl, e.namespaceArgs(parsed))
+	defer wait()
+	failed := 0
+	for _, r := range results {
+		<-r.done
+		if r.err != nil {
+			failed++
+			e.writeLine(fmt.Sprintf("--- %s (%s) failed", r.cluster, r.took.Round(time.Millisecond)))
+		} else {
+			e.writeLine(fmt.Sprintf("--- %s (%s)", r.cluster, r.took.Round(time.Millisecond)))
+		}
+		if _, err := e.rw.Write(r.out.Bytes()); err != nil {
+			_log.Infow("unable to write output", "error", err)
+		}
+		if r.err != nil {
+			e.writeError(r.err)
+		}
+	}
+	e.writeLine(fmt.Sprintf("--- %d of %d clusters succeeded in %s", len(clusters)-failed, len(clusters), time.Since(start).Round(time.Millisecond)))
+	_log.Infow("executed fanout kubectl", "args", pl.kubectl, "filters", pl.filters, "clusters", clusters, "failed", failed)
+}
+
+// fanoutRun starts the command on the clusters with a bounded number of
+// workers, the results are in the order of clusters. wait returns once the
+// workers ended.
+func (e *ioExecutor) fanoutRun(ctx context.Context, clusters []string, pl *pipeline, namespaceArgs []string) (results []*fanoutResult, wait func()) {
+	workers := e.fanoutWorkers
+	if workers <= 0 {
+		workers = DefaultFanoutWorkers
+	}
+	if workers > len(clusters) {
+		workers = len(clusters)
+	}
+	limit := e.outputLimit / int64(len(clusters))
+	if limit < fanoutMinOutput {
+		limit = fanoutMinOutput
+	}
+
+	results = make([]*fanoutResult, len(clusters))
+	jobs := make(chan *fanoutResult, len(clusters))
+	for i, name := range clusters {
+		results[i] = &fanoutResult{cluster: name, done: make(chan struct{})}
+		jobs <- results[i]
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				e.fanoutCluster(ctx, r, pl, namespaceArgs, limit)
+				close(r.done)
+			}
+		}()
+	}
+	return results, wg.Wait
+}
+
+// fanoutCluster runs the command on the cluster of the result.
+func (e *ioExecutor) fanoutCluster(ctx context.Context, r *fanoutResult, pl *pipeline, namespaceArgs []string, limit int64) {
+	start := time.Now()
+	defer func() {
+		r.took = time.Since(start)
+	}()
+	if ctx.Err() != nil {
+		r.err = errors.New("interrupted")
+		return
+	}
+
+	args, release, err := e.fanout.ClusterArgs(ctx, r.cluster)
+	if err != nil {
+		_log.Infow("unable to get kubeconfig", "cluster", r.cluster, "error", err)
+		r.err = fmt.Errorf("unable to get kubeconfig: %v", err)
+		return
+	}
+	defer release()
+
+	cctx, cancel := context.WithTimeout(ctx, e.pipelineTimeout)
+	defer cancel()
+	out := newOutputWriter(&r.out, limit, cancel)
+	err = pl.run(cctx, e.kubectlBin, append(append([]string{}, args...), namespaceArgs...), out)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		r.err = errors.New("interrupted")
+	case err != nil && !out.truncated:
+		r.err = err
+	}
+}
+
+// fanoutCommand returns the document with the fanout selector removed, so
+// that the command is completed like any other.
+func fanoutCommand(d prompt.Document) prompt.Document {
+	before := d.TextBeforeCursor()
+	if !strings.HasPrefix(before, "fanout ") {
+		return d
+	}
+	i := strings.Index(before, " -- ")
+	if i < 0 {
+		return d
+	}
+
+	b := prompt.NewBuffer()
+	b.InsertText(strings.TrimLeft(before[i+4:], " "), false, true)
+	b.InsertText(d.TextAfterCursor(), false, false)
+	return *b.Document()
+}
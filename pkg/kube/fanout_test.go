@@ -0,0 +1,153 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/paralus/prompt/pkg/prompt"
+)
+
+type fakeClusterFanout struct {
+	m        sync.Mutex
+	clusters []string
+	released []string
+}
+
+func (f *fakeClusterFanout) Clusters(ctx context.Context) ([]string, error) {
+	return f.clusters, nil
+}
+
+func (f *fakeClusterFanout) ClusterArgs(ctx context.Context, name string) ([]string, func(), error) {
+	if name == "prod-us" {
+		return nil, nil, errors.New("cluster unreachable")
+	}
+	return []string{"--kubeconfig", "/tmp/" + name}, func() {
+		f.m.Lock()
+		defer f.m.Unlock()
+		f.released = append(f.released, name)
+	}, nil
+}
+
+func TestMatchClusters(t *testing.T) {
+	names := []string{"prod-us", "dev", "prod-eu", "staging"}
+	scenarioTable := []struct {
+		selector string
+		expected []string
+	}{
+		{selector: "prod-*", expected: []string{"prod-eu", "prod-us"}},
+		{selector: "dev,staging", expected: []string{"dev", "staging"}},
+		{selector: "*", expected: []string{"dev", "prod-eu", "prod-us", "staging"}},
+		{selector: "qa", expected: nil},
+	}
+	for _, s := range scenarioTable {
+		actual, err := matchClusters(names, s.selector)
+		if err != nil || !reflect.DeepEqual(actual, s.expected) {
+			t.Errorf("%q: should be %v, got %v, %v", s.selector, s.expected, actual, err)
+		}
+	}
+	if _, err := matchClusters(names, "prod-["); err == nil {
+		t.Errorf("invalid selector should fail")
+	}
+}
+
+func TestFanout(t *testing.T) {
+	var out bytes.Buffer
+	f := &fakeClusterFanout{clusters: []string{"dev", "prod-eu", "prod-us"}}
+	e := &ioExecutor{
+		rw:              &out,
+		kubectlBin:      "echo",
+		policy:          DefaultPolicy(),
+		pipelineTimeout: defaultPipelineTimeout,
+		outputLimit:     DefaultOutputLimit,
+		interactive:     DefaultInteractiveRules(),
+		fanoutWorkers:   2,
+	}
+	OptionDefaultNamespace("default")(e)
+	OptionClusterFanout(f)(e)
+	e.aliases, _ = LoadAliases("")
+
+	e.runBuiltin(context.Background(), "use-namespace payments")
+	if !e.runBuiltin(context.Background(), "fanout prod-* -- get nodes") {
+		t.Fatalf("fanout is not run as builtin")
+	}
+
+	expected := regexp.MustCompile(`^--- prod-eu \(\S+\)\r\n` +
+		`get nodes --kubeconfig /tmp/prod-eu --namespace payments\r\n` +
+		`--- prod-us \(\S+\) failed\r\n` +
+		`error: unable to get kubeconfig: cluster unreachable\r\n` +
+		`--- 1 of 2 clusters succeeded in \S+\r\n$`)
+	if !expected.MatchString(out.String()) {
+		t.Errorf("output = %q", out.String())
+	}
+	if !reflect.DeepEqual(f.released, []string{"prod-eu"}) {
+		t.Errorf("released = %v", f.released)
+	}
+
+	for input, message := range map[string]string{
+		"fanout * -- delete pod web":    `error: fanout only runs read-only commands, "delete" is not allowed`,
+		"fanout * -- get pods -w":       "error: fanout does not run interactive commands",
+		"fanout qa -- get nodes":        "error: no cluster matches qa",
+		"fanout prod-* get nodes":       "error: usage: fanout selector -- command",
+		"fanout * -- config view --raw": `error: fanout only runs read-only commands, "config view" is not allowed`,
+	} {
+		out.Reset()
+		e.runBuiltin(context.Background(), input)
+		if !strings.HasPrefix(out.String(), message) {
+			t.Errorf("%q: output = %q", input, out.String())
+		}
+	}
+}
+
+func TestCompleteFanout(t *testing.T) {
+	b := prompt.NewBuffer()
+	b.InsertText("fanout prod-* -- get po", false, true)
+	if d := fanoutCommand(*b.Document()); d.TextBeforeCursor() != "get po" {
+		t.Errorf("fanoutCommand() = %q", d.TextBeforeCursor())
+	}
+
+	c := &Completer{clusterNames: func() []string { return []string{"dev", "prod-eu"} }}
+	suggestions := c.argumentsCompleter("default", []string{"fanout", "pro"})
+	if !reflect.DeepEqual(suggestions, []prompt.Suggest{{Text: "prod-eu"}}) {
+		t.Errorf("argumentsCompleter() = %v", suggestions)
+	}
+}
+
+func TestFanoutRunArgs(t *testing.T) {
+	var clusters []string
+	for i := 0; i < 16; i++ {
+		clusters = append(clusters, fmt.Sprintf("cluster-%02d", i))
+	}
+	e := &ioExecutor{
+		kubectlBin:      "echo",
+		pipelineTimeout: defaultPipelineTimeout,
+		outputLimit:     DefaultOutputLimit,
+		fanoutWorkers:   8,
+	}
+	OptionClusterFanout(&fakeClusterFanout{clusters: clusters})(e)
+
+	pl, err := parsePipeline("get pods -n kube-system -o wide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, wait := e.fanoutRun(context.Background(), clusters, pl, nil)
+	wait()
+	for _, r := range results {
+		if r.err != nil {
+			t.Fatalf("%s: %v", r.cluster, r.err)
+		}
+		expected := "get pods -n kube-system -o wide --kubeconfig /tmp/" + r.cluster + "\r\n"
+		if r.out.String() != expected {
+			t.Errorf("%s: should run with %q, got %q", r.cluster, expected, r.out.String())
+		}
+	}
+	if expected := []string{"get", "pods", "-n", "kube-system", "-o", "wide"}; !reflect.DeepEqual(pl.kubectl, expected) {
+		t.Errorf("pipeline should be %q, got %q", expected, pl.kubectl)
+	}
+}
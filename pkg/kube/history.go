@@ -0,0 +1,171 @@
+package kube
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/paralus/prompt/pkg/prompt"
+)
+
+const (
+	// DefaultHistorySize is the number of commands kept in a history file.
+	DefaultHistorySize = 1000
+	// maxHistoryEntry is the length above which commands are not stored.
+	maxHistoryEntry = 4096
+)
+
+const redacted = "*****"
+
+var (
+	// secretFlags are flags whose values are credentials, e.g. --token=abc or
+	// --password 'a b'.
+	secretFlags = regexp.MustCompile(`(?i)(--(?:token|password|docker-password|client-key|client-secret|auth-token|bearer-token)(?:=|\s+))('[^']*'|"[^"]*"|\S+)`)
+	// literalValues are values of secrets created from literals, e.g.
+	// --from-literal=key=value.
+	literalValues = regexp.MustCompile(`(--from-literal(?:=|\s+)[^=\s]+=)('[^']*'|"[^"]*"|\S+)`)
+	bearerTokens  = regexp.MustCompile(`(?i)(bearer\s+)[^\s'"]+`)
+)
+
+// redactCommand replaces credentials in a command, so that they are not
+// stored in the history.
+func redactCommand(s string) string {
+	s = secretFlags.ReplaceAllString(s, "${1}"+redacted)
+	s = literalValues.ReplaceAllString(s, "${1}"+redacted)
+	return bearerTokens.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// FileHistoryStore stores the history of commands in a file with one json
+// string per line. Credentials are redacted and only the latest commands
+// are kept.
+type FileHistoryStore struct {
+	m    sync.Mutex
+	path string
+	size int
+	// count is the number of lines in the file
+	count int
+}
+
+var _ prompt.HistoryStore = &FileHistoryStore{}
+
+// NewFileHistoryStore returns history store for the file at path, which
+// keeps up to size commands.
+func NewFileHistoryStore(path string, size int) *FileHistoryStore {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &FileHistoryStore{path: path, size: size}
+}
+
+// Load returns the stored commands, oldest first.
+func (s *FileHistoryStore) Load() ([]string, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > s.size {
+		entries = entries[len(entries)-s.size:]
+	}
+	return entries, nil
+}
+
+func (s *FileHistoryStore) read() ([]string, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 2*maxHistoryEntry)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	s.count = len(entries)
+	return entries, scanner.Err()
+}
+
+// Append stores the command with credentials redacted.
+func (s *FileHistoryStore) Append(input string) error {
+	if len(input) > maxHistoryEntry {
+		return nil
+	}
+	line, err := json.Marshal(redactCommand(input))
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	// the file is compacted once it holds twice the size, so that it is
+	// not rewritten on every command.
+	s.count++
+	if s.count > 2*s.size {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact rewrites the file with the latest commands.
+func (s *FileHistoryStore) compact() error {
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	if len(entries) > s.size {
+		entries = entries[len(entries)-s.size:]
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, entry := range entries {
+		line, _ := json.Marshal(entry)
+		w.Write(append(line, '\n'))
+	}
+	err = w.Flush()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	s.count = len(entries)
+	return nil
+}
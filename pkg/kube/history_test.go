@@ -0,0 +1,73 @@
+package kube
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRedactCommand(t *testing.T) {
+	scenarioTable := []struct {
+		input    string
+		expected string
+	}{
+		{input: "get pods -n kube-system", expected: "get pods -n kube-system"},
+		{input: "get pods --token=abc.def", expected: "get pods --token=*****"},
+		{input: "get pods --token abc.def -o wide", expected: "get pods --token ***** -o wide"},
+		{
+			input:    "create secret docker-registry reg --docker-username=bob --docker-password='p4ss word'",
+			expected: "create secret docker-registry reg --docker-username=bob --docker-password=*****",
+		},
+		{
+			input:    "create secret generic db --from-literal=user=admin --from-literal password=s3cr3t",
+			expected: "create secret generic db --from-literal=user=***** --from-literal password=*****",
+		},
+		{
+			input:    `run curl --image=curl -- curl -H "Authorization: Bearer eyJhbGc" http://svc`,
+			expected: `run curl --image=curl -- curl -H "Authorization: Bearer *****" http://svc`,
+		},
+	}
+
+	for _, s := range scenarioTable {
+		if actual := redactCommand(s.input); actual != s.expected {
+			t.Errorf("%q: should be %q, got %q", s.input, s.expected, actual)
+		}
+	}
+}
+
+func TestFileHistoryStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user", "cluster")
+	s := NewFileHistoryStore(path, 3)
+
+	entries, err := s.Load()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("should be empty, got %v, %v", entries, err)
+	}
+
+	for i := 0; i < 8; i++ {
+		if err := s.Append(fmt.Sprintf("get pods %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Append("get secrets --token=abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = NewFileHistoryStore(path, 3).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"get pods 6", "get pods 7", "get secrets --token=*****"}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("should be %v, got %v", expected, entries)
+	}
+
+	// compacted after twice the size
+	s.m.Lock()
+	all, _ := s.read()
+	s.m.Unlock()
+	if len(all) > 6 {
+		t.Errorf("should be compacted, got %d entries", len(all))
+	}
+}
@@ -0,0 +1,82 @@
+package kube
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// InteractiveRule matches kubectl commands which are run attached to a pseudo
+// terminal, as they read input from the user or render a terminal ui.
+type InteractiveRule struct {
+	// Verbs are kubectl subcommands, config matches config view as well and
+	// * matches any.
+	Verbs []string `json:"verbs"`
+	// Flags of which any has to be set, every command of the verbs matches
+	// if empty.
+	Flags []string `json:"flags,omitempty"`
+}
+
+// defaultInteractiveRules are evaluated before any configured rule.
+var defaultInteractiveRules = []InteractiveRule{
+	{Verbs: []string{"exec", "run"}, Flags: []string{"-i", "--stdin", "-t", "--tty"}},
+	{Verbs: []string{"attach", "edit", "debug"}},
+	{Verbs: []string{"logs"}, Flags: []string{"-f", "--follow"}},
+	{Verbs: []string{"*"}, Flags: []string{"-w", "--watch", "--watch-only"}},
+}
+
+// DefaultInteractiveRules returns the rules for the interactive commands of
+// kubectl.
+func DefaultInteractiveRules() []InteractiveRule {
+	return append([]InteractiveRule{}, defaultInteractiveRules...)
+}
+
+// LoadInteractiveRules reads rules from a yaml or json file, e.g. for
+// plugins, the rules of the file extend the default rules.
+func LoadInteractiveRules(path string) ([]InteractiveRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []InteractiveRule
+	if err := yaml.UnmarshalStrict(b, &rules); err != nil {
+		return nil, fmt.Errorf("unable to parse interactive commands %s: %w", path, err)
+	}
+	for i, r := range rules {
+		if len(r.Verbs) == 0 {
+			return nil, fmt.Errorf("interactive command rule %d: no verbs", i)
+		}
+	}
+	return append(DefaultInteractiveRules(), rules...), nil
+}
+
+func (r *InteractiveRule) matches(cmd *command) bool {
+	if !matchAny(r.Verbs, func(v string) bool {
+		return v == "*" || cmd.Verb == v || strings.HasPrefix(cmd.Verb, v+" ")
+	}) {
+		return false
+	}
+	if len(r.Flags) == 0 {
+		return true
+	}
+	return matchAny(r.Flags, func(f string) bool {
+		v, ok := cmd.Flags[f]
+		return ok && v != "false"
+	})
+}
+
+// isInteractive reports whether the command matches any of the rules.
+func isInteractive(cmd *command, rules []InteractiveRule) bool {
+	if cmd.Verb == "" {
+		return false
+	}
+	for i := range rules {
+		if rules[i].matches(cmd) {
+			return true
+		}
+	}
+	return false
+}
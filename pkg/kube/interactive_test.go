@@ -0,0 +1,123 @@
+package kube
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsInteractive(t *testing.T) {
+	scenarioTable := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "exec -it web-0 -- sh", expected: true},
+		{input: "exec -ti web-0 -c app -- sh", expected: true},
+		{input: "exec --stdin --tty web-0 -- sh", expected: true},
+		{input: "exec -i web-0 -- cat", expected: true},
+		{input: "attach web-0", expected: true},
+		{input: "edit deploy web", expected: true},
+		{input: "run -it debug --image=busybox --restart=Never", expected: true},
+		{input: "debug node/node-1 --image=busybox", expected: true},
+		{input: "get pods -w", expected: true},
+		{input: "get pods --watch", expected: true},
+		{input: "get pods --watch-only -n prod", expected: true},
+		{input: "rollout status deploy/web -w", expected: true},
+		{input: "logs -f web-0", expected: true},
+		{input: "logs --follow web-0 -c app", expected: true},
+		{input: "logs -nprod -f web-0", expected: true},
+
+		// false positives of matching the command line
+		{input: "get pods -l app=webexec", expected: false},
+		{input: "get cm edit-config", expected: false},
+		{input: "describe pod logs-shipper-0", expected: false},
+		{input: "get pods --show-kind -wide", expected: false},
+		{input: "get pods -o wide", expected: false},
+		{input: "get pods -n watch-system", expected: false},
+		{input: "exec web-0 -- ls -w", expected: false},
+		{input: "exec web-0 -- ls", expected: false},
+		{input: "run web --image=nginx", expected: false},
+		{input: "logs web-0", expected: false},
+		{input: "get pods --watch=false", expected: false},
+		{input: "apply -f watch.yaml", expected: false},
+		{input: "", expected: false},
+	}
+
+	rules := DefaultInteractiveRules()
+	for _, s := range scenarioTable {
+		actual := isInteractive(parseCommand(strings.Fields(s.input)), rules)
+		if actual != s.expected {
+			t.Errorf("%q: should be %v, got %v", s.input, s.expected, actual)
+		}
+	}
+}
+
+func TestLoadInteractiveRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interactive.yaml")
+	err := os.WriteFile(path, []byte(`
+- verbs: [ns, ctx]
+- verbs: [port-forward]
+  flags: [--interactive]
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadInteractiveRules(path)
+	if err != nil {
+		t.Fatalf("LoadInteractiveRules() error = %v", err)
+	}
+	for input, expected := range map[string]bool{
+		"ns":                             true,
+		"ctx prod":                       true,
+		"port-forward web 8080":          false,
+		"port-forward web --interactive": true,
+		"exec -it web-0 -- sh":           true,
+		"get pods -l app=webexec":        false,
+	} {
+		if actual := isInteractive(parseCommand(strings.Fields(input)), rules); actual != expected {
+			t.Errorf("%q: should be %v, got %v", input, expected, actual)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`- flags: [-i]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadInteractiveRules(path); err == nil {
+		t.Errorf("LoadInteractiveRules() should fail for rules without verbs")
+	}
+}
+
+func TestExecuteInteractiveExit(t *testing.T) {
+	term := &testTerminal{in: make(chan []byte)}
+	// only reads like the websocket, io.Copy would use the buffer otherwise
+	rw := struct {
+		io.Reader
+		io.Writer
+		ContextReader
+	}{term, term, term}
+	execute := NewIOExecutor(rw, NewTerminal(24, 80), nil, nil, "true", nil)
+
+	// the command exits without reading input
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		execute(context.Background(), "exec -it web-0 -- sh")
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("executor did not return after the command exited")
+	}
+
+	// input after the command exited is left to the prompt
+	select {
+	case term.in <- []byte("a"):
+		t.Error("input read after the command exited")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
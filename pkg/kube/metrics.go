@@ -0,0 +1,109 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Command statuses of the commands metric.
+const (
+	commandSuccess     = "success"
+	commandError       = "error"
+	commandInterrupted = "interrupted"
+	commandFailed      = "failed"
+	commandDenied      = "denied"
+)
+
+var (
+	commandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "prompt",
+		Name:      "commands_total",
+		Help:      "Kubectl commands run by verb and exit status.",
+	}, []string{"verb", "status"})
+	commandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "prompt",
+		Name:      "command_duration_seconds",
+		Help:      "Run time of kubectl commands by verb.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"verb"})
+	completionFetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "prompt",
+		Name:      "completion_fetch_duration_seconds",
+		Help:      "Time to list resources for completion by resource type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"resource"})
+	completionFetchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "prompt",
+		Name:      "completion_fetch_errors_total",
+		Help:      "Failed lists of resources for completion by resource type.",
+	}, []string{"resource"})
+)
+
+// RegisterMetrics registers the metrics of commands and completion with r.
+func RegisterMetrics(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{commandsTotal, commandDuration, completionFetchDuration, completionFetchErrors} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verbLabel returns the verb of the command as metric label, commands which
+// are not kubectl commands are counted as other.
+func verbLabel(cmd *command) string {
+	verb := strings.Split(cmd.Verb, " ")[0]
+	if verb == "" {
+		return "none"
+	}
+	for _, c := range commands {
+		if c.Text == verb {
+			return verb
+		}
+	}
+	return "other"
+}
+
+// commandStatus returns the status of a command which ended with err, ctx is
+// the context the command was run with.
+func commandStatus(ctx context.Context, err error) string {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return commandSuccess
+	case ctx.Err() != nil:
+		return commandInterrupted
+	case errors.As(err, &exitErr):
+		return commandError
+	default:
+		return commandFailed
+	}
+}
+
+// observeCommand records a command which ran for d.
+func observeCommand(cmd *command, status string, d time.Duration) {
+	verb := verbLabel(cmd)
+	commandsTotal.WithLabelValues(verb, status).Inc()
+	if status != commandDenied {
+		commandDuration.WithLabelValues(verb).Observe(d.Seconds())
+	}
+}
+
+// timedList records the time and errors of listing the resource type for
+// completion.
+func timedList(resource string, list func() (interface{}, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		start := time.Now()
+		l, err := list()
+		completionFetchDuration.WithLabelValues(resource).Observe(time.Since(start).Seconds())
+		if err != nil {
+			completionFetchErrors.WithLabelValues(resource).Inc()
+		}
+		return l, err
+	}
+}
@@ -0,0 +1,65 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestVerbLabel(t *testing.T) {
+	for input, expected := range map[string]string{
+		"get pods":          "get",
+		"config view --raw": "config",
+		"rollout status x":  "rollout",
+		"foo bar":           "other",
+		"":                  "none",
+	} {
+		if actual := verbLabel(parseCommand(strings.Fields(input))); actual != expected {
+			t.Errorf("%q: should be %s, got %s", input, expected, actual)
+		}
+	}
+}
+
+func TestCommandStatus(t *testing.T) {
+	exitErr := exec.Command("false").Run()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	scenarioTable := []struct {
+		ctx      context.Context
+		err      error
+		expected string
+	}{
+		{ctx: context.Background(), err: nil, expected: commandSuccess},
+		{ctx: context.Background(), err: exitErr, expected: commandError},
+		{ctx: cancelled, err: exitErr, expected: commandInterrupted},
+		{ctx: context.Background(), err: errors.New("not found"), expected: commandFailed},
+	}
+	for _, s := range scenarioTable {
+		if actual := commandStatus(s.ctx, s.err); actual != s.expected {
+			t.Errorf("%v: should be %s, got %s", s.err, s.expected, actual)
+		}
+	}
+}
+
+func TestTimedList(t *testing.T) {
+	before := testutil.ToFloat64(completionFetchErrors.WithLabelValues("secrets"))
+	timedList("secrets", func() (interface{}, error) { return nil, errors.New("forbidden") })()
+	timedList("secrets", func() (interface{}, error) { return []string{}, nil })()
+	if n := testutil.ToFloat64(completionFetchErrors.WithLabelValues("secrets")) - before; n != 1 {
+		t.Errorf("errors = %v, want 1", n)
+	}
+
+	r := prometheus.NewRegistry()
+	if err := RegisterMetrics(r); err != nil {
+		t.Fatalf("RegisterMetrics() error = %v", err)
+	}
+	if n, err := testutil.GatherAndCount(r, "prompt_completion_fetch_duration_seconds"); err != nil || n == 0 {
+		t.Errorf("completion fetch duration not gathered: %d, %v", n, err)
+	}
+}
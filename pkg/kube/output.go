@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// DefaultOutputLimit is the number of bytes of output shown per command.
+const DefaultOutputLimit = 10 << 20
+
+// ContextReader is implemented by terminals whose reads can be cancelled, so
+// that Ctrl-C can be read while a command runs without taking input from the
+// prompt once it ended.
+type ContextReader interface {
+	ReadContext(ctx context.Context, p []byte) (int, error)
+}
+
+// readerWithContext returns a reader of r whose reads end with ctx, if r is
+// a ContextReader.
+func readerWithContext(ctx context.Context, r io.Reader) io.Reader {
+	cr, ok := r.(ContextReader)
+	if !ok {
+		return r
+	}
+	return contextReaderFunc(func(p []byte) (int, error) {
+		return cr.ReadContext(ctx, p)
+	})
+}
+
+type contextReaderFunc func(p []byte) (int, error)
+
+func (f contextReaderFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// outputWriter streams the output of a command to the terminal, translating
+// line feeds to carriage return and line feed. Output beyond limit bytes is
+// dropped with a notice and the command is stopped with cancel.
+type outputWriter struct {
+	w         io.Writer
+	limit     int64
+	written   int64
+	truncated bool
+	failed    bool
+	cancel    func()
+}
+
+func newOutputWriter(w io.Writer, limit int64, cancel func()) *outputWriter {
+	return &outputWriter{w: w, limit: limit, cancel: cancel}
+}
+
+// Write never fails, so that the command is not blocked on a full pipe, it is
+// stopped instead if the terminal can not be written.
+func (o *outputWriter) Write(p []byte) (int, error) {
+	if o.truncated || o.failed {
+		return len(p), nil
+	}
+
+	out := p
+	if o.limit > 0 && o.written+int64(len(p)) > o.limit {
+		out = p[:o.limit-o.written]
+		o.truncated = true
+	}
+	o.written += int64(len(out))
+
+	var err error
+	if len(out) > 0 {
+		_, err = o.w.Write(bytes.ReplaceAll(out, []byte{'\n'}, []byte{'\r', '\n'}))
+	}
+	if err == nil && o.truncated {
+		_, err = o.w.Write([]byte(fmt.Sprintf("\r\n... output truncated after %d bytes\r\n", o.limit)))
+	}
+	if err != nil {
+		_log.Infow("unable to write output", "error", err)
+		o.failed = true
+	}
+	if o.truncated || o.failed {
+		o.cancel()
+	}
+	return len(p), nil
+}
+
+// watchInterrupt returns context of a command which is cancelled when the
+// user presses Ctrl-C, and the function to stop watching once the command
+// ended. Other input while the command runs is dropped.
+func (e *ioExecutor) watchInterrupt(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	r, ok := e.rw.(ContextReader)
+	if !ok {
+		return ctx, cancel
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1024)
+		for {
+			n, err := r.ReadContext(ctx, buf)
+			if err != nil {
+				return
+			}
+			if bytes.IndexByte(buf[:n], 0x03) >= 0 {
+				e.rw.Write([]byte("^C\r\n"))
+				cancel()
+				return
+			}
+		}
+	}()
+	return ctx, func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,122 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOutputWriter(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int64
+		writes    []string
+		want      string
+		truncated bool
+	}{
+		{
+			name:   "translates line feeds",
+			writes: []string{"NAME\n", "api-0\nap", "i-1\n"},
+			want:   "NAME\r\napi-0\r\napi-1\r\n",
+		},
+		{
+			name:      "truncates at limit",
+			limit:     8,
+			writes:    []string{"NAME\n", "api-0\n", "api-1\n"},
+			want:      "NAME\r\napi\r\n... output truncated after 8 bytes\r\n",
+			truncated: true,
+		},
+		{
+			name:   "output of limit",
+			limit:  11,
+			writes: []string{"NAME\n", "api-0\n"},
+			want:   "NAME\r\napi-0\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var cancelled bool
+			o := newOutputWriter(&buf, tt.limit, func() { cancelled = true })
+			for _, w := range tt.writes {
+				if n, err := o.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if o.truncated != tt.truncated || cancelled != tt.truncated {
+				t.Errorf("truncated = %v, cancelled = %v, want %v", o.truncated, cancelled, tt.truncated)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
+func TestOutputWriterFailed(t *testing.T) {
+	var cancelled int
+	o := newOutputWriter(failingWriter{}, 0, func() { cancelled++ })
+	for i := 0; i < 2; i++ {
+		if _, err := o.Write([]byte("NAME\n")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if cancelled != 1 {
+		t.Errorf("cancelled %d times, want 1", cancelled)
+	}
+}
+
+// testTerminal is a terminal whose input is sent on a channel.
+type testTerminal struct {
+	bytes.Buffer
+	in chan []byte
+}
+
+func (t *testTerminal) ReadContext(ctx context.Context, p []byte) (int, error) {
+	select {
+	case b := <-t.in:
+		return copy(p, b), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (t *testTerminal) Read(p []byte) (int, error) {
+	return t.ReadContext(context.Background(), p)
+}
+
+func TestWatchInterrupt(t *testing.T) {
+	term := &testTerminal{in: make(chan []byte)}
+	e := &ioExecutor{rw: term}
+
+	ctx, stop := e.watchInterrupt(context.Background())
+	defer stop()
+	term.in <- []byte("a")
+	if ctx.Err() != nil {
+		t.Fatalf("cancelled on input other than Ctrl-C")
+	}
+	term.in <- []byte{0x03}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("not cancelled on Ctrl-C")
+	}
+
+	// input after the command ended is left to the prompt
+	_, stop = e.watchInterrupt(context.Background())
+	stop()
+	select {
+	case term.in <- []byte{0x03}:
+		t.Errorf("input read after the command ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
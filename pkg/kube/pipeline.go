@@ -0,0 +1,328 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-shellwords"
+)
+
+const defaultPipelineTimeout = 5 * time.Minute
+
+// filter describes a binary which is allowed to consume kubectl output in a
+// pipeline. Filters only ever read their stdin, arguments which would make
+// them read or write local files or run other commands are rejected.
+type filter struct {
+	// maxArgs is the number of positional arguments accepted, e.g. the grep
+	// pattern. Any further positional argument would be read as a file.
+	maxArgs int
+	// patternFlags give the pattern instead of the first positional
+	// argument, which is then read as a file too.
+	patternFlags []string
+	// subcommands are positional arguments not counted against maxArgs.
+	subcommands []string
+	// valueFlags take the given number of following arguments as values.
+	valueFlags map[string]int
+	// deniedFlags read or write local files or run commands.
+	deniedFlags []string
+	// check validates positional arguments and flag values, e.g. the awk
+	// program.
+	check func(arg string) error
+}
+
+var filters = map[string]filter{
+	"grep": {
+		maxArgs:      1,
+		patternFlags: []string{"-e", "--regexp"},
+		valueFlags: map[string]int{
+			"-e": 1, "--regexp": 1, "-m": 1, "--max-count": 1,
+			"-A": 1, "--after-context": 1, "-B": 1, "--before-context": 1,
+			"-C": 1, "--context": 1, "--label": 1,
+		},
+		deniedFlags: []string{
+			"-f", "--file", "-r", "--recursive", "-R", "--dereference-recursive",
+			"-d", "--directories", "-D", "--devices", "--include", "--exclude",
+			"--exclude-from", "--exclude-dir",
+		},
+	},
+	"awk": {
+		maxArgs:    1,
+		valueFlags: map[string]int{"-F": 1, "--field-separator": 1, "-v": 1, "--assign": 1},
+		deniedFlags: []string{
+			"-f", "--file", "-e", "--source", "-i", "--include", "-l", "--load",
+			"-E", "--exec",
+		},
+		check: checkAwkProgram,
+	},
+	"jq": {
+		maxArgs:    1,
+		valueFlags: map[string]int{"--arg": 2, "--argjson": 2, "--indent": 1},
+		deniedFlags: []string{
+			"-f", "--from-file", "-L", "--rawfile", "--slurpfile",
+			"--args", "--jsonargs", "--run-tests",
+		},
+		check: checkQueryProgram,
+	},
+	"yq": {
+		maxArgs:     1,
+		subcommands: []string{"e", "eval", "ea", "eval-all"},
+		valueFlags:  map[string]int{"-o": 1, "--output-format": 1, "-p": 1, "--input-format": 1, "-I": 1, "--indent": 1},
+		deniedFlags: []string{"-i", "--inplace", "--from-file", "-s", "--split-exp"},
+		check:       checkQueryProgram,
+	},
+	"sort": {
+		valueFlags: map[string]int{
+			"-k": 1, "--key": 1, "-t": 1, "--field-separator": 1,
+			"-S": 1, "--buffer-size": 1, "--parallel": 1,
+		},
+		deniedFlags: []string{
+			"-o", "--output", "--files0-from", "-T", "--temporary-directory",
+			"--compress-program", "--random-source",
+		},
+	},
+	"head": {
+		valueFlags: map[string]int{"-n": 1, "--lines": 1, "-c": 1, "--bytes": 1},
+	},
+	"wc": {
+		deniedFlags: []string{"--files0-from"},
+	},
+}
+
+var (
+	// ARGV and ARGC name files to read, @ calls functions by name, e.g.
+	// system, in gawk.
+	awkUnsafe      = regexp.MustCompile(`\b(system|getline|ARGV|ARGC|ENVIRON|PROCINFO)\b|@`)
+	awkRedirection = regexp.MustCompile(`\bprintf?\b[^;{}]*[>|]`)
+	// yq reads files with load, strload, load_xml and other load operators,
+	// names after a dot are fields, e.g. .status.loadBalancer.
+	queryUnsafe = regexp.MustCompile(`(^|[^.\w$])(import|include|input_filename|modulemeta|strload|load\w*|\w*load_\w*)\b`)
+)
+
+func checkAwkProgram(program string) error {
+	if awkUnsafe.MatchString(program) || awkRedirection.MatchString(program) {
+		return errors.New("awk programs may not run commands, read the environment, read or redirect to files")
+	}
+	return nil
+}
+
+func checkQueryProgram(program string) error {
+	if queryUnsafe.MatchString(program) {
+		return errors.New("queries may not read files")
+	}
+	return nil
+}
+
+// validate checks the argv of a filter stage, argv[0] is the filter name.
+func (f filter) validate(argv []string) error {
+	name := argv[0]
+	maxArgs := f.maxArgs
+	var files []string
+	for i := 1; i < len(argv); i++ {
+		arg := argv[i]
+		var flag string
+		var values []string
+		switch {
+		case arg == "-" || arg == "--":
+			// stdin and end of flags
+			continue
+		case !strings.HasPrefix(arg, "-"):
+			if len(files) == 0 && contains(f.subcommands, arg) {
+				continue
+			}
+			files = append(files, arg)
+			values = []string{arg}
+		case strings.HasPrefix(arg, "--"):
+			parts := strings.SplitN(arg, "=", 2)
+			flag = parts[0]
+			if contains(f.deniedFlags, flag) {
+				return fmt.Errorf("%s: flag %s is not allowed", name, flag)
+			}
+			if len(parts) == 2 {
+				values = parts[1:]
+			} else if n, ok := f.valueFlags[flag]; ok {
+				values = argv[i+1 : min(i+1+n, len(argv))]
+				i += n
+			}
+		default:
+			// short flags may be combined, e.g. grep -in, and the last one may
+			// take the rest of the argument as value, e.g. awk -F:
+			for j := 1; j < len(arg); j++ {
+				short := "-" + string(arg[j])
+				if contains(f.deniedFlags, short) {
+					return fmt.Errorf("%s: flag %s is not allowed", name, short)
+				}
+				if n, ok := f.valueFlags[short]; ok {
+					flag = short
+					if j == len(arg)-1 {
+						values = argv[i+1 : min(i+1+n, len(argv))]
+						i += n
+					} else {
+						values = []string{arg[j+1:]}
+					}
+					break
+				}
+			}
+		}
+
+		if contains(f.patternFlags, flag) {
+			maxArgs = 0
+		}
+		if f.check != nil {
+			for _, v := range values {
+				if err := f.check(v); err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				}
+			}
+		}
+	}
+
+	// the pattern may follow the positional arguments, e.g. grep foo -e bar
+	if len(files) > maxArgs {
+		return fmt.Errorf("%s: reading files is not allowed (%s)", name, files[maxArgs])
+	}
+	return nil
+}
+
+// pipeline is a kubectl command and the filters its output is piped through.
+type pipeline struct {
+	kubectl []string
+	filters [][]string
+}
+
+// parsePipeline splits the command line on pipes, the first stage is passed
+// to kubectl and every following stage must be an allowed filter.
+func parsePipeline(s string) (*pipeline, error) {
+	var stages [][]string
+	line := []rune(s)
+	for {
+		p := shellwords.NewParser()
+		argv, err := p.Parse(string(line))
+		if err != nil {
+			return nil, err
+		}
+		if len(argv) == 0 {
+			return nil, errors.New("empty command in pipeline")
+		}
+		stages = append(stages, argv)
+		if p.Position < 0 {
+			break
+		}
+		if op := line[p.Position]; op != '|' {
+			return nil, fmt.Errorf("unsupported shell operator %q", op)
+		}
+		line = line[p.Position+1:]
+	}
+
+	pl := &pipeline{kubectl: stages[0]}
+	for _, argv := range stages[1:] {
+		f, ok := filters[argv[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s is not allowed in a pipeline, allowed commands are %s", argv[0], strings.Join(filterNames(), ", "))
+		}
+		if err := f.validate(argv); err != nil {
+			return nil, err
+		}
+		pl.filters = append(pl.filters, argv)
+	}
+	return pl, nil
+}
+
+// run executes the pipeline and writes output of the last stage to out, errors
+// of all stages are written after it.
+func (pl *pipeline) run(ctx context.Context, kubectlBin string, defaultArgs []string, out io.Writer) error {
+	// the pipeline is shared, e.g. by the clusters of fanout, so the args are
+	// not appended to its slice.
+	args := append(append([]string{}, pl.kubectl...), defaultArgs...)
+	var cmds []*exec.Cmd
+	cmds = append(cmds, exec.CommandContext(ctx, kubectlBin, args...))
+	for _, argv := range pl.filters {
+		bin, err := exec.LookPath(argv[0])
+		if err != nil {
+			return fmt.Errorf("%s is not available", argv[0])
+		}
+		cmd := exec.CommandContext(ctx, bin, argv[1:]...)
+		// filters do not need the server environment, which jq and yq could read.
+		cmd.Env = []string{}
+		cmds = append(cmds, cmd)
+	}
+
+	// the pipe ends are closed in this process once the commands are started,
+	// so that a filter exiting early, e.g. head, ends the commands before it.
+	var pipes []*os.File
+	defer func() {
+		for _, f := range pipes {
+			f.Close()
+		}
+	}()
+
+	stderr := make([]bytes.Buffer, len(cmds))
+	for i, cmd := range cmds {
+		cmd.Stderr = &stderr[i]
+		if i == len(cmds)-1 {
+			cmd.Stdout = out
+			break
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		pipes = append(pipes, r, w)
+		cmd.Stdout = w
+		cmds[i+1].Stdin = r
+	}
+
+	var started []*exec.Cmd
+	var err error
+	for _, cmd := range cmds {
+		if err = cmd.Start(); err != nil {
+			break
+		}
+		started = append(started, cmd)
+	}
+	for _, f := range pipes {
+		f.Close()
+	}
+	pipes = nil
+
+	for i, cmd := range started {
+		werr := cmd.Wait()
+		if i == len(cmds)-1 && err == nil {
+			err = werr
+		}
+	}
+
+	for i := range stderr {
+		out.Write(stderr[i].Bytes())
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("pipeline timed out")
+	}
+	return err
+}
+
+func filterNames() []string {
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
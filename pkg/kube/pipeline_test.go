@@ -0,0 +1,128 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	scenarioTable := []struct {
+		input    string
+		expected *pipeline
+		err      bool
+	}{
+		{
+			input:    "get pods",
+			expected: &pipeline{kubectl: []string{"get", "pods"}},
+		},
+		{
+			input: "get pods -A | grep 'crash loop' | wc -l",
+			expected: &pipeline{
+				kubectl: []string{"get", "pods", "-A"},
+				filters: [][]string{{"grep", "crash loop"}, {"wc", "-l"}},
+			},
+		},
+		{
+			input: `get pods -o json | jq '.items[] | .metadata.name'`,
+			expected: &pipeline{
+				kubectl: []string{"get", "pods", "-o", "json"},
+				filters: [][]string{{"jq", ".items[] | .metadata.name"}},
+			},
+		},
+		{
+			input: "get pods | awk -F: '$3 > 5 { print $1 }' | sort -k 2 | head -n 5",
+			expected: &pipeline{
+				kubectl: []string{"get", "pods"},
+				filters: [][]string{{"awk", "-F:", "$3 > 5 { print $1 }"}, {"sort", "-k", "2"}, {"head", "-n", "5"}},
+			},
+		},
+		{
+			input: "get pods | grep -e foo --regexp=bar -i",
+			expected: &pipeline{
+				kubectl: []string{"get", "pods"},
+				filters: [][]string{{"grep", "-e", "foo", "--regexp=bar", "-i"}},
+			},
+		},
+		{
+			input: `get svc -o yaml | yq '.items[].status.loadBalancer.ingress'`,
+			expected: &pipeline{
+				kubectl: []string{"get", "svc", "-o", "yaml"},
+				filters: [][]string{{"yq", ".items[].status.loadBalancer.ingress"}},
+			},
+		},
+		{input: "get pods | sh", err: true},
+		{input: "get pods | grep foo /etc/passwd", err: true},
+		{input: "get pods | grep -rn foo", err: true},
+		{input: "get pods | grep -e foo /etc/passwd", err: true},
+		{input: "get pods | grep --regexp=foo /etc/passwd", err: true},
+		{input: "get pods | grep /etc/passwd -e foo", err: true},
+		{input: "get pods | grep -efoo /etc/passwd", err: true},
+		{input: "get pods | awk --source='BEGIN{system(\"id\")}'", err: true},
+		{input: "get pods | awk -e 'BEGIN{system(\"id\")}'", err: true},
+		{input: "get pods | awk -f /tmp/prog.awk", err: true},
+		{input: "get pods | awk -v x=1 --lint='{ system(\"id\") }'", err: true},
+		{input: "get pods | sort -o /tmp/out", err: true},
+		{input: "get pods | awk '{ system(\"id\") }'", err: true},
+		{input: "get pods | awk '{ print > \"/tmp/out\" }'", err: true},
+		{input: `get pods | awk 'BEGIN{ARGV[1]="/etc/hostname";ARGC=2}{print}'`, err: true},
+		{input: `get pods | awk '{ print ENVIRON["HOME"] }'`, err: true},
+		{input: `get pods | awk 'BEGIN{f="system"; @f("id")}'`, err: true},
+		{input: `get pods | awk '@include "/etc/passwd"'`, err: true},
+		{input: "get pods | jq -f /etc/passwd", err: true},
+		{input: `get pods | jq 'import "foo" as foo; .'`, err: true},
+		{input: `get pods | yq 'load("/etc/passwd")'`, err: true},
+		{input: `get pods | yq 'load_str("/etc/passwd")'`, err: true},
+		{input: `get pods | yq 'strload("/etc/passwd")'`, err: true},
+		{input: `get pods | yq 'load_props("/etc/passwd")'`, err: true},
+		{input: `get pods | yq 'load_xml("/etc/passwd")'`, err: true},
+		{input: `get pods | yq 'load_base64("/etc/passwd")'`, err: true},
+		{input: `get pods | yq '.a | load_base64("/etc/passwd")'`, err: true},
+		{input: "get pods |", err: true},
+		{input: "get pods || grep foo", err: true},
+		{input: "get pods > /tmp/out", err: true},
+		{input: "get pods; id", err: true},
+	}
+
+	for _, s := range scenarioTable {
+		t.Run(s.input, func(t *testing.T) {
+			actual, err := parsePipeline(s.input)
+			if s.err {
+				if err == nil {
+					t.Errorf("Should be error, but got %#v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Should not be error, but got %v", err)
+			}
+			if !reflect.DeepEqual(actual, s.expected) {
+				t.Errorf("Should be %#v, but got %#v", s.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	for _, bin := range []string{"printf", "grep", "head"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s is not available", bin)
+		}
+	}
+	printf, _ := exec.LookPath("printf")
+
+	pl, err := parsePipeline(`'foo\nbar\nbaz\n' | grep ba | head -n 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := pl.run(context.Background(), printf, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "bar\n" {
+		t.Errorf("Should be %q, but got %q", "bar\n", out.String())
+	}
+}
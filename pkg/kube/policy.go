@@ -0,0 +1,167 @@
+package kube
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// PolicyAction is the decision of a command policy.
+type PolicyAction string
+
+const (
+	// PolicyAllow runs the command.
+	PolicyAllow PolicyAction = "allow"
+	// PolicyDeny blocks the command and tells the user why.
+	PolicyDeny PolicyAction = "deny"
+	// PolicyConfirm asks the user to confirm before running the command.
+	PolicyConfirm PolicyAction = "confirm"
+)
+
+// PolicyRule matches kubectl commands, every non empty field has to match and
+// a field matches if any of its values does.
+type PolicyRule struct {
+	Name   string       `json:"name"`
+	Action PolicyAction `json:"action"`
+	// Verbs are kubectl subcommands, config matches config view as well.
+	Verbs []string `json:"verbs,omitempty"`
+	// Resources are resource types, short and singular names are accepted.
+	Resources []string `json:"resources,omitempty"`
+	// Namespaces the command targets, commands for all namespaces match any.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Flags present on the command, either --flag or --flag=value.
+	Flags  []string `json:"flags,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+// Policy is an ordered list of rules, the first matching rule decides.
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+	// Default is the action for commands no rule matches, allow if empty.
+	Default PolicyAction `json:"default,omitempty"`
+}
+
+// PolicyDecision is the result of evaluating a command against the policy.
+type PolicyDecision struct {
+	Action PolicyAction
+	Rule   string
+	Reason string
+}
+
+// builtinRules are evaluated before any configured rule.
+var builtinRules = []PolicyRule{
+	{
+		Name:   "config-view-credentials",
+		Action: PolicyDeny,
+		Verbs:  []string{"config view"},
+		Flags:  []string{"--raw", "--flatten"},
+		Reason: "displaying kubeconfig credentials is not allowed",
+	},
+}
+
+// DefaultPolicy returns the policy which only applies the builtin rules.
+func DefaultPolicy() *Policy {
+	return &Policy{Rules: append([]PolicyRule{}, builtinRules...), Default: PolicyAllow}
+}
+
+// LoadPolicy reads policy rules from a yaml or json file, builtin rules
+// are evaluated before the rules of the file.
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Policy
+	if err := yaml.UnmarshalStrict(b, &p); err != nil {
+		return nil, fmt.Errorf("unable to parse policy %s: %w", path, err)
+	}
+
+	if p.Default == "" {
+		p.Default = PolicyAllow
+	}
+	if err := validateAction(p.Default); err != nil {
+		return nil, err
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if err := validateAction(r.Action); err != nil {
+			return nil, fmt.Errorf("rule %d %s: %w", i, r.Name, err)
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i)
+		}
+		for j := range r.Resources {
+			r.Resources[j] = normalizeResource(r.Resources[j])
+		}
+	}
+	p.Rules = append(append([]PolicyRule{}, builtinRules...), p.Rules...)
+	return &p, nil
+}
+
+func validateAction(a PolicyAction) error {
+	switch a {
+	case PolicyAllow, PolicyDeny, PolicyConfirm:
+		return nil
+	}
+	return fmt.Errorf("invalid policy action %q", a)
+}
+
+// Evaluate returns the decision of the first rule matching the command.
+func (p *Policy) Evaluate(cmd *command) PolicyDecision {
+	for _, r := range p.Rules {
+		if r.matches(cmd) {
+			return PolicyDecision{Action: r.Action, Rule: r.Name, Reason: r.Reason}
+		}
+	}
+	return PolicyDecision{Action: p.Default, Reason: "no rule matched"}
+}
+
+func (r *PolicyRule) matches(cmd *command) bool {
+	if len(r.Verbs) > 0 && !matchAny(r.Verbs, func(v string) bool {
+		return cmd.Verb == v || strings.HasPrefix(cmd.Verb, v+" ")
+	}) {
+		return false
+	}
+	if len(r.Resources) > 0 && !matchAny(r.Resources, func(res string) bool {
+		return res == "*" || contains(cmd.Resources, res)
+	}) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && !cmd.AllNamespaces && !matchAny(r.Namespaces, func(ns string) bool {
+		return ns == "*" || ns == cmd.Namespace
+	}) {
+		return false
+	}
+	if len(r.Flags) > 0 && !matchAny(r.Flags, func(f string) bool {
+		name, value := f, ""
+		if i := strings.Index(f, "="); i > 0 {
+			name, value = f[:i], f[i+1:]
+		}
+		v, ok := cmd.Flags[name]
+		return ok && (value == "" || value == v)
+	}) {
+		return false
+	}
+	return true
+}
+
+func matchAny(l []string, fn func(string) bool) bool {
+	for _, v := range l {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Meta returns the decision as audit event meta data.
+func (d PolicyDecision) Meta() map[string]string {
+	return map[string]string{
+		"policy_action": string(d.Action),
+		"policy_rule":   d.Rule,
+		"policy_reason": d.Reason,
+	}
+}
@@ -0,0 +1,95 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `
+default: allow
+rules:
+- name: no-secrets
+  action: deny
+  verbs: [get, describe]
+  resources: [secret]
+  reason: reading secrets is not allowed
+- name: prod-writes
+  action: confirm
+  verbs: [delete, scale, drain, rollout]
+  namespaces: [prod]
+  reason: this changes production
+- name: force-delete
+  action: deny
+  verbs: [delete]
+  flags: [--force, --grace-period=0]
+  reason: force deletion is not allowed
+`
+
+func TestPolicyEvaluate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(testPolicy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarioTable := []struct {
+		input     string
+		namespace string
+		action    PolicyAction
+		rule      string
+	}{
+		{input: "get pods", action: PolicyAllow},
+		{input: "config view --raw", action: PolicyDeny, rule: "config-view-credentials"},
+		{input: "config view --flatten --minify", action: PolicyDeny, rule: "config-view-credentials"},
+		{input: "config view", action: PolicyAllow},
+		{input: "--v 5 config view --raw", action: PolicyDeny, rule: "config-view-credentials"},
+		{input: "-v5 config view --raw", action: PolicyDeny, rule: "config-view-credentials"},
+		{input: "--log-file-max-size 10 config view --flatten", action: PolicyDeny, rule: "config-view-credentials"},
+		{input: "--verbosity 5 config view --raw", action: PolicyDeny, rule: "config-view-credentials"},
+		{input: "--insecure-skip-tls-verify config view --raw", action: PolicyDeny, rule: "config-view-credentials"},
+		{input: "--v 5 get secrets -n kube-system", action: PolicyDeny, rule: "no-secrets"},
+		{input: "get secrets -n kube-system", action: PolicyDeny, rule: "no-secrets"},
+		{input: "describe secret/db", action: PolicyDeny, rule: "no-secrets"},
+		{input: "delete secret db", action: PolicyAllow},
+		{input: "delete pod web-0 -n prod", action: PolicyConfirm, rule: "prod-writes"},
+		{input: "delete pod web-0", namespace: "prod", action: PolicyConfirm, rule: "prod-writes"},
+		{input: "rollout restart deploy/web -A", action: PolicyConfirm, rule: "prod-writes"},
+		{input: "delete pod web-0 -n dev", action: PolicyAllow},
+		{input: "delete pod web-0 -n dev --grace-period=0", action: PolicyDeny, rule: "force-delete"},
+		{input: "delete pod web-0 -n dev --grace-period=30", action: PolicyAllow},
+	}
+
+	for _, s := range scenarioTable {
+		cmd := parseCommand(strings.Fields(s.input))
+		if cmd.Namespace == "" && !cmd.AllNamespaces {
+			cmd.Namespace = s.namespace
+		}
+		d := p.Evaluate(cmd)
+		if d.Action != s.action || d.Rule != s.rule {
+			t.Errorf("%q: should be %s by %q, got %s by %q", s.input, s.action, s.rule, d.Action, d.Rule)
+		}
+	}
+}
+
+func TestLoadPolicyInvalid(t *testing.T) {
+	scenarioTable := []string{
+		"rules:\n- action: block\n",
+		"default: maybe\n",
+		"rules:\n- action: deny\n  verb: [get]\n",
+	}
+
+	for _, s := range scenarioTable {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		if err := os.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPolicy(path); err == nil {
+			t.Errorf("%q: should fail to load", s)
+		}
+	}
+}
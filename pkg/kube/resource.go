@@ -1,189 +1,94 @@
 package kube
 
 import (
-	"context"
 	"fmt"
 	"sort"
-	"sync"
-	"sync/atomic"
-	"time"
 
-	"github.com/paralus/prompt/internal/debug"
 	prompt "github.com/paralus/prompt/pkg/prompt"
-	appsv1 "k8s.io/api/apps/v1"
-	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
-	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
-const thresholdFetchInterval = 10 * time.Second
-
-var ctx = context.Background() // Temporary
-
-func init() {
-	lastFetchedAt = new(sync.Map)
-	podList = new(sync.Map)
-	endpointList = new(sync.Map)
-	deploymentList = new(sync.Map)
-	daemonSetList = new(sync.Map)
-	eventList = new(sync.Map)
-	secretList = new(sync.Map)
-	ingressList = new(sync.Map)
-	limitRangeList = new(sync.Map)
-	persistentVolumeClaimsList = new(sync.Map)
-	podTemplateList = new(sync.Map)
-	replicaSetList = new(sync.Map)
-	replicationControllerList = new(sync.Map)
-	resourceQuotaList = new(sync.Map)
-	serviceAccountList = new(sync.Map)
-	serviceList = new(sync.Map)
-	jobList = new(sync.Map)
+// cacheKey returns the key of a resource type in a namespace of the cluster,
+// cluster scoped resource types have an empty namespace.
+func (c *Completer) cacheKey(resource, namespace string) string {
+	return c.cluster + "/" + namespace + "/" + resource
 }
 
-/* LastFetchedAt */
-
-var (
-	lastFetchedAt *sync.Map
-)
-
-func shouldFetch(key string) bool {
-	v, ok := lastFetchedAt.Load(key)
+// cachedSuggestions returns the suggestions cached for the resource type in
+// the namespace, list is called in the background to update them.
+func (c *Completer) cachedSuggestions(resource, namespace string, list func() ([]prompt.Suggest, error)) []prompt.Suggest {
+	s, ok := c.cache.get(c.cacheKey(resource, namespace), timedList(resource, func() (interface{}, error) {
+		return list()
+	})).([]prompt.Suggest)
 	if !ok {
-		return true
-	}
-	t, ok := v.(time.Time)
-	if !ok {
-		return true
-	}
-	return time.Since(t) > thresholdFetchInterval
-}
-
-func updateLastFetchedAt(key string) {
-	lastFetchedAt.Store(key, time.Now())
-}
-
-/* Component Status */
-
-var (
-	componentStatusList atomic.Value
-)
-
-func fetchComponentStatusList(client *kubernetes.Clientset) {
-	key := "component_status"
-	if !shouldFetch(key) {
-		return
+		return []prompt.Suggest{}
 	}
-	l, _ := client.CoreV1().ComponentStatuses().List(ctx, metav1.ListOptions{})
-	componentStatusList.Store(l)
-	updateLastFetchedAt(key)
+	// callers may append to the suggestions
+	return s[:len(s):len(s)]
 }
 
-func getComponentStatusCompletions(client *kubernetes.Clientset) []prompt.Suggest {
-	go fetchComponentStatusList(client)
-	l, ok := componentStatusList.Load().(*corev1.ComponentStatusList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
+func nameSuggestions(names []string) []prompt.Suggest {
+	s := make([]prompt.Suggest, len(names))
+	for i := range names {
 		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+			Text: names[i],
 		}
 	}
 	return s
 }
 
-/* Config Maps */
-
-var (
-	configMapsList atomic.Value
-)
+/* Component Status */
 
-func fetchConfigMapList(client *kubernetes.Clientset, namespace string) {
-	key := "config_map_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-	l, _ := client.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
-	configMapsList.Store(l)
+func (c *Completer) getComponentStatusCompletions() []prompt.Suggest {
+	return c.cachedSuggestions("componentstatuses", "", func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().ComponentStatuses().List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
-func getConfigMapSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchConfigMapList(client, namespace)
-	l, ok := configMapsList.Load().(*corev1.ConfigMapList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+/* Config Maps */
+
+func (c *Completer) getConfigMapSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("configmaps", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().ConfigMaps(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
-/* Contexts */
-
-// var (
-// 	contextList atomic.Value
-// )
-
-// func fetchContextList() {
-// 	key := "context"
-// 	if !shouldFetch(key) {
-// 		return
-// 	}
-// 	updateLastFetchedAt(key)
-// 	r := ExecuteAndGetResult("config get-contexts --no-headers -o name")
-// 	r = strings.TrimRight(r, "\n")
-// 	contextList.Store(strings.Split(r, "\n"))
-// }
-
-// func getContextSuggestions() []prompt.Suggest {
-// 	go fetchContextList()
-// 	l, ok := contextList.Load().([]string)
-// 	if !ok || len(l) == 0 {
-// 		return []prompt.Suggest{}
-// 	}
-// 	s := make([]prompt.Suggest, len(l))
-// 	for i := range l {
-// 		s[i] = prompt.Suggest{
-// 			Text: l[i],
-// 		}
-// 	}
-// 	return s
-// }
-
 /* Pod */
 
-var (
-	podList *sync.Map
-)
-
-func fetchPods(client *kubernetes.Clientset, namespace string) {
-	key := "pod_" + namespace
-	if !shouldFetch(key) {
-		return
+// getPodList returns the cached pods, which are kept as a whole for
+// container and port completion.
+func (c *Completer) getPodList(namespace string) *corev1.PodList {
+	if c.watches != nil {
+		if l, ok := c.watches.pods(namespace); ok {
+			return l
+		}
 	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
-	podList.Store(namespace, l)
+	l, _ := c.cache.get(c.cacheKey("pods", namespace), timedList("pods", func() (interface{}, error) {
+		return c.client.CoreV1().Pods(namespace).List(c.ctx, metav1.ListOptions{})
+	})).(*corev1.PodList)
+	return l
 }
 
-func getPodSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchPods(client, namespace)
-	x, ok := podList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.PodList)
-	if !ok || len(l.Items) == 0 {
+func (c *Completer) getPodSuggestions(namespace string) []prompt.Suggest {
+	l := c.getPodList(namespace)
+	if l == nil || len(l.Items) == 0 {
 		return []prompt.Suggest{}
 	}
 	s := make([]prompt.Suggest, len(l.Items))
@@ -196,13 +101,9 @@ func getPodSuggestions(client *kubernetes.Clientset, namespace string) []prompt.
 	return s
 }
 
-func getPod(namespace, podName string) (corev1.Pod, bool) {
-	x, ok := podList.Load(namespace)
-	if !ok {
-		return corev1.Pod{}, false
-	}
-	l, ok := x.(*corev1.PodList)
-	if !ok || len(l.Items) == 0 {
+func (c *Completer) getPod(namespace, podName string) (corev1.Pod, bool) {
+	l := c.getPodList(namespace)
+	if l == nil || len(l.Items) == 0 {
 		return corev1.Pod{}, false
 	}
 	for i := range l.Items {
@@ -213,8 +114,8 @@ func getPod(namespace, podName string) (corev1.Pod, bool) {
 	return corev1.Pod{}, false
 }
 
-func getPortsFromPodName(namespace string, podName string) []prompt.Suggest {
-	pod, found := getPod(namespace, podName)
+func (c *Completer) getPortsFromPodName(namespace string, podName string) []prompt.Suggest {
+	pod, found := c.getPod(namespace, podName)
 	if !found {
 		return []prompt.Suggest{}
 	}
@@ -245,15 +146,9 @@ func getPortsFromPodName(namespace string, podName string) []prompt.Suggest {
 	return suggests
 }
 
-func getContainerNamesFromCachedPods(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchPods(client, namespace)
-
-	x, ok := podList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.PodList)
-	if !ok || len(l.Items) == 0 {
+func (c *Completer) getContainerNamesFromCachedPods(namespace string) []prompt.Suggest {
+	l := c.getPodList(namespace)
+	if l == nil || len(l.Items) == 0 {
 		return []prompt.Suggest{}
 	}
 	// container name -> pod name
@@ -273,10 +168,8 @@ func getContainerNamesFromCachedPods(client *kubernetes.Clientset, namespace str
 	return s
 }
 
-func getContainerName(client *kubernetes.Clientset, namespace string, podName string) []prompt.Suggest {
-	go fetchPods(client, namespace)
-
-	pod, found := getPod(namespace, podName)
+func (c *Completer) getContainerName(namespace string, podName string) []prompt.Suggest {
+	pod, found := c.getPod(namespace, podName)
 	if !found {
 		return []prompt.Suggest{}
 	}
@@ -292,298 +185,135 @@ func getContainerName(client *kubernetes.Clientset, namespace string, podName st
 
 /* Daemon Sets */
 
-var (
-	daemonSetList *sync.Map
-)
-
-func fetchDaemonSetList(client *kubernetes.Clientset, namespace string) {
-	key := "daemon_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
-	daemonSetList.Store(namespace, l)
-	return
-}
-
-func getDaemonSetSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchDaemonSetList(client, namespace)
-	x, ok := daemonSetList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(appsv1.DaemonSetList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getDaemonSetSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("daemonsets", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.AppsV1().DaemonSets(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Deployment */
 
-var (
-	deploymentList *sync.Map
-)
-
-func fetchDeployments(client *kubernetes.Clientset, namespace string) {
-	key := "deployment_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
-	deploymentList.Store(namespace, l)
-	return
-}
-
-func getDeploymentSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchDeployments(client, namespace)
-	x, ok := deploymentList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*appsv1.DeploymentList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getDeploymentSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("deployments", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.AppsV1().Deployments(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Endpoint */
 
-var (
-	endpointList *sync.Map
-)
-
-func fetchEndpoints(client *kubernetes.Clientset, namespace string) {
-	key := "endpoint_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().Endpoints(namespace).List(ctx, metav1.ListOptions{})
-	endpointList.Store(key, l)
-	return
-}
-
-func getEndpointsSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchEndpoints(client, namespace)
-	x, ok := endpointList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.EndpointsList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getEndpointsSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("endpoints", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().Endpoints(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Events */
 
-var (
-	eventList *sync.Map
-)
-
-func fetchEvents(client *kubernetes.Clientset, namespace string) {
-	key := "event_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
-	eventList.Store(namespace, l)
-	return
-}
-
-func getEventsSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchEvents(client, namespace)
-	x, ok := eventList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.EventList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getEventsSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("events", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().Events(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Node */
 
-var (
-	nodeList atomic.Value
-)
-
-func fetchNodeList(client *kubernetes.Clientset) {
-	key := "node"
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
-	nodeList.Store(l)
-	return
-}
-
-func getNodeSuggestions(client *kubernetes.Clientset) []prompt.Suggest {
-	go fetchNodeList(client)
-	l, ok := nodeList.Load().(*corev1.NodeList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getNodeSuggestions() []prompt.Suggest {
+	if c.watches != nil {
+		if names, ok := c.watches.names(corev1.SchemeGroupVersion.WithResource("nodes"), ""); ok {
+			return nameSuggestions(names)
 		}
 	}
-	return s
+	return c.cachedSuggestions("nodes", "", func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().Nodes().List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Secret */
 
-var (
-	secretList *sync.Map
-)
-
-func fetchSecretList(client *kubernetes.Clientset, namespace string) {
-	key := "secret_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
-	secretList.Store(namespace, l)
-	return
-}
-
-func getSecretSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchSecretList(client, namespace)
-	x, ok := secretList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.SecretList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getSecretSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("secrets", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().Secrets(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Ingress */
 
-var (
-	ingressList *sync.Map
-)
-
-func fetchIngresses(client *kubernetes.Clientset, namespace string) {
-	key := "ingress_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.ExtensionsV1beta1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
-	ingressList.Store(namespace, l)
-}
-
-func getIngressSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchIngresses(client, namespace)
-
-	x, ok := ingressList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*extensionsv1beta1.IngressList)
-	if !ok {
-		debug.Log("must not reach here")
-		return []prompt.Suggest{}
-	}
-	if len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getIngressSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("ingresses", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.NetworkingV1().Ingresses(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* LimitRange */
 
-var (
-	limitRangeList *sync.Map
-)
-
-func fetchLimitRangeList(client *kubernetes.Clientset, namespace string) {
-	key := "limit_range_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().LimitRanges(namespace).List(ctx, metav1.ListOptions{})
-	limitRangeList.Store(namespace, l)
-	return
-}
-
-func getLimitRangeSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchLimitRangeList(client, namespace)
-	x, ok := limitRangeList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.NamespaceList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getLimitRangeSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("limitranges", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().LimitRanges(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* NameSpaces */
@@ -603,365 +333,150 @@ func getNameSpaceSuggestions(namespaceList *corev1.NamespaceList) []prompt.Sugge
 
 /* Persistent Volume Claims */
 
-var (
-	persistentVolumeClaimsList *sync.Map
-)
-
-func fetchPersistentVolumeClaimsList(client *kubernetes.Clientset, namespace string) {
-	key := "persistent_volume_claims" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
-	persistentVolumeClaimsList.Store(namespace, l)
-	return
-}
-
-func getPersistentVolumeClaimSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchPersistentVolumeClaimsList(client, namespace)
-	x, ok := persistentVolumeClaimsList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.PersistentVolumeClaimList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getPersistentVolumeClaimSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("persistentvolumeclaims", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().PersistentVolumeClaims(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Persistent Volumes */
 
-var (
-	persistentVolumesList atomic.Value
-)
-
-func fetchPersistentVolumeList(client *kubernetes.Clientset) {
-	key := "persistent_volume"
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
-	persistentVolumesList.Store(l)
-	return
-}
-
-func getPersistentVolumeSuggestions(client *kubernetes.Clientset) []prompt.Suggest {
-	go fetchPersistentVolumeList(client)
-	l, ok := persistentVolumesList.Load().(*corev1.PersistentVolumeList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getPersistentVolumeSuggestions() []prompt.Suggest {
+	return c.cachedSuggestions("persistentvolumes", "", func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().PersistentVolumes().List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
-}
-
-/* Pod Security Policies */
-
-var (
-	podSecurityPolicyList atomic.Value
-)
-
-func fetchPodSecurityPolicyList(client *kubernetes.Clientset) {
-	key := "pod_security_policy"
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.ExtensionsV1beta1().PodSecurityPolicies().List(ctx, metav1.ListOptions{})
-	podSecurityPolicyList.Store(l)
-	return
-}
-
-func getPodSecurityPolicySuggestions(client *kubernetes.Clientset) []prompt.Suggest {
-	go fetchPodSecurityPolicyList(client)
-	l, ok := podSecurityPolicyList.Load().(policyv1beta1.PodSecurityPolicyList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
 		}
-	}
-	return s
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Pod Templates */
 
-var (
-	podTemplateList *sync.Map
-)
-
-func fetchPodTemplateList(client *kubernetes.Clientset, namespace string) {
-	key := "pod_template_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().PodTemplates(namespace).List(ctx, metav1.ListOptions{})
-	podTemplateList.Store(namespace, l)
-	return
-}
-
-func getPodTemplateSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchPodTemplateList(client, namespace)
-	x, ok := podTemplateList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.PodTemplateList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getPodTemplateSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("podtemplates", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().PodTemplates(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Replica Sets */
 
-var (
-	replicaSetList *sync.Map
-)
-
-func fetchReplicaSetList(client *kubernetes.Clientset, namespace string) {
-	key := "replica_set_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.AppsV1beta2().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
-	replicaSetList.Store(namespace, l)
-	return
-}
-
-func getReplicaSetSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchReplicaSetList(client, namespace)
-	x, ok := replicaSetList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(appsv1.ReplicaSetList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getReplicaSetSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("replicasets", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.AppsV1().ReplicaSets(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Replication Controller */
 
-var (
-	replicationControllerList *sync.Map
-)
-
-func fetchReplicationControllerList(client *kubernetes.Clientset, namespace string) {
-	key := "replication_controller" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().ReplicationControllers(namespace).List(ctx, metav1.ListOptions{})
-	replicationControllerList.Store(namespace, l)
-	return
-}
-
-func getReplicationControllerSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchReplicationControllerList(client, namespace)
-	x, ok := replicationControllerList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.ReplicationControllerList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getReplicationControllerSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("replicationcontrollers", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().ReplicationControllers(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Resource quotas */
 
-var (
-	resourceQuotaList *sync.Map
-)
-
-func fetchResourceQuotaList(client *kubernetes.Clientset, namespace string) {
-	key := "resource_quota" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
-	resourceQuotaList.Store(namespace, l)
-	return
-}
-
-func getResourceQuotasSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchResourceQuotaList(client, namespace)
-	x, ok := resourceQuotaList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.ResourceQuotaList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getResourceQuotasSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("resourcequotas", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().ResourceQuotas(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Service Account */
 
-var (
-	serviceAccountList *sync.Map
-)
-
-func fetchServiceAccountList(client *kubernetes.Clientset, namespace string) {
-	key := "service_account_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().ServiceAccounts(namespace).List(ctx, metav1.ListOptions{})
-	serviceAccountList.Store(namespace, l)
-	return
-}
-
-func getServiceAccountSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchServiceAccountList(client, namespace)
-	x, ok := serviceAccountList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.ServiceAccountList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getServiceAccountSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("serviceaccounts", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().ServiceAccounts(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Service */
 
-var (
-	serviceList *sync.Map
-)
-
-func fetchServiceList(client *kubernetes.Clientset, namespace string) {
-	key := "service_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
-	serviceList.Store(namespace, l)
-	return
-}
-
-func getServiceSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchServiceList(client, namespace)
-	x, ok := serviceList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*corev1.ServiceList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		s[i] = prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getServiceSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("services", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.CoreV1().Services(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
-	return s
+		names := make([]string, len(l.Items))
+		for i := range l.Items {
+			names[i] = l.Items[i].Name
+		}
+		return nameSuggestions(names), nil
+	})
 }
 
 /* Job */
 
-var (
-	jobList *sync.Map
-)
-
-func fetchJobs(client *kubernetes.Clientset, namespace string) {
-	key := "job_" + namespace
-	if !shouldFetch(key) {
-		return
-	}
-	updateLastFetchedAt(key)
-
-	l, _ := client.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
-	jobList.Store(namespace, l)
-}
-
-func getJobSuggestions(client *kubernetes.Clientset, namespace string) []prompt.Suggest {
-	go fetchJobs(client, namespace)
-	x, ok := jobList.Load(namespace)
-	if !ok {
-		return []prompt.Suggest{}
-	}
-	l, ok := x.(*batchv1.JobList)
-	if !ok || len(l.Items) == 0 {
-		return []prompt.Suggest{}
-	}
-	s := make([]prompt.Suggest, len(l.Items))
-	for i := range l.Items {
-		suggestion := prompt.Suggest{
-			Text: l.Items[i].Name,
+func (c *Completer) getJobSuggestions(namespace string) []prompt.Suggest {
+	return c.cachedSuggestions("jobs", namespace, func() ([]prompt.Suggest, error) {
+		l, err := c.client.BatchV1().Jobs(namespace).List(c.ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-		if l.Items[i].Status.StartTime != nil {
-			suggestion.Description = l.Items[i].Status.StartTime.String()
+		s := make([]prompt.Suggest, len(l.Items))
+		for i := range l.Items {
+			suggestion := prompt.Suggest{
+				Text: l.Items[i].Name,
+			}
+			if l.Items[i].Status.StartTime != nil {
+				suggestion.Description = l.Items[i].Status.StartTime.String()
+			}
+			s[i] = suggestion
 		}
-		s[i] = suggestion
-	}
-	return s
+		return s, nil
+	})
 }
@@ -0,0 +1,60 @@
+package kube
+
+import (
+	"os"
+	"sync"
+
+	"github.com/creack/pty"
+)
+
+// Terminal tracks the size of the remote terminal and the pty of the
+// interactive command attached to it, so that resizes reach running children.
+type Terminal struct {
+	m          sync.Mutex
+	rows, cols uint16
+	pty        *os.File
+}
+
+// NewTerminal returns terminal with the initial size
+func NewTerminal(rows, cols uint16) *Terminal {
+	return &Terminal{rows: rows, cols: cols}
+}
+
+// Size returns the current size of the terminal
+func (t *Terminal) Size() *pty.Winsize {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return &pty.Winsize{Rows: t.rows, Cols: t.cols}
+}
+
+// Resize updates the terminal size and resizes the attached pty if any
+func (t *Terminal) Resize(rows, cols uint16) {
+	if rows == 0 || cols == 0 {
+		return
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.rows, t.cols = rows, cols
+	if t.pty == nil {
+		return
+	}
+	err := pty.Setsize(t.pty, &pty.Winsize{Rows: rows, Cols: cols})
+	if err != nil {
+		_log.Infow("unable to resize pty", "error", err)
+	}
+}
+
+// attach starts forwarding resizes to the pty of an interactive command
+func (t *Terminal) attach(f *os.File) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.pty = f
+}
+
+// detach stops forwarding resizes, must be called before the pty is closed
+func (t *Terminal) detach() {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.pty = nil
+}
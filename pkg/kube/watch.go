@@ -0,0 +1,248 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiwatch "k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/pager"
+)
+
+const (
+	// maxWatchObjects is the number of objects above which a resource type
+	// is not watched, its names are listed on completion instead.
+	maxWatchObjects = 5000
+	// watchPageSize is the number of objects a watch lists at once, so that
+	// listing stops early for resource types with too many objects.
+	watchPageSize = 500
+)
+
+var errTooManyObjects = errors.New("too many objects to watch")
+
+// watchCache keeps the objects of the resource types a session completes up
+// to date with informers. Informers only hold object metadata, except for
+// pods whose containers and ports are completed as well. At most max
+// resource types are watched, the least recently completed one is stopped
+// to watch another one. Watches are started in the background, completion
+// lists the objects itself until they are synced.
+type watchCache struct {
+	client   kubernetes.Interface
+	metadata metadata.Interface
+	max      int
+
+	m       sync.Mutex
+	watches map[string]*watch
+	// skipped are resource types which can not be watched, e.g. forbidden.
+	skipped map[string]bool
+	closed  bool
+}
+
+type watch struct {
+	informer cache.SharedIndexInformer
+	stop     chan struct{}
+	lastUsed time.Time
+}
+
+func newWatchCache(client kubernetes.Interface, md metadata.Interface, max int) *watchCache {
+	return &watchCache{
+		client:   client,
+		metadata: md,
+		max:      max,
+		watches:  map[string]*watch{},
+		skipped:  map[string]bool{},
+	}
+}
+
+// names returns the names of the objects of the resource type in the
+// namespace, false if the resource type is not watched or not synced yet.
+func (wc *watchCache) names(gvr schema.GroupVersionResource, namespace string) ([]string, bool) {
+	client := wc.metadata.Resource(gvr).Namespace(namespace)
+	informer, ok := wc.informer(gvr.String()+"/"+namespace, &metav1.PartialObjectMetadata{}, &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (apiwatch.Interface, error) {
+			return client.Watch(context.TODO(), options)
+		},
+	})
+	if !ok {
+		return nil, false
+	}
+
+	objs := informer.GetStore().List()
+	names := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		if o, ok := obj.(metav1.Object); ok {
+			names = append(names, o.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// pods returns the pods in the namespace, false if pods are not watched or
+// not synced yet.
+func (wc *watchCache) pods(namespace string) (*corev1.PodList, bool) {
+	client := wc.client.CoreV1().Pods(namespace)
+	informer, ok := wc.informer("pods/"+namespace, &corev1.Pod{}, &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (apiwatch.Interface, error) {
+			return client.Watch(context.TODO(), options)
+		},
+	})
+	if !ok {
+		return nil, false
+	}
+
+	objs := informer.GetStore().List()
+	l := &corev1.PodList{Items: make([]corev1.Pod, 0, len(objs))}
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			l.Items = append(l.Items, *pod)
+		}
+	}
+	sort.Slice(l.Items, func(i, j int) bool {
+		return l.Items[i].Name < l.Items[j].Name
+	})
+	return l, true
+}
+
+// informer returns the synced informer for key. It starts watching the
+// objects listed by lw if the key is not watched yet and returns false until
+// they are synced.
+func (wc *watchCache) informer(key string, objType runtime.Object, lw *cache.ListWatch) (cache.SharedIndexInformer, bool) {
+	wc.m.Lock()
+	if wc.closed || wc.skipped[key] {
+		wc.m.Unlock()
+		return nil, false
+	}
+
+	w, ok := wc.watches[key]
+	if ok {
+		w.lastUsed = time.Now()
+		wc.m.Unlock()
+		if !w.informer.HasSynced() {
+			return nil, false
+		}
+		// the list is limited, but the objects may grow by watch events
+		if len(w.informer.GetStore().ListKeys()) > maxWatchObjects {
+			_log.Infow("too many objects to watch", "key", key)
+			wc.skip(key, w)
+			return nil, false
+		}
+		return w.informer, true
+	}
+
+	if len(wc.watches) >= wc.max {
+		wc.evictLocked()
+	}
+	w = &watch{
+		stop:     make(chan struct{}),
+		lastUsed: time.Now(),
+	}
+	list := lw.ListFunc
+	lw.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		l, err := listLimited(list, options)
+		// the reflector does not wrap list errors, they are checked here
+		if isUnwatchable(err) {
+			_log.Infow("unable to watch", "key", key, "error", err)
+			wc.skip(key, w)
+		}
+		return l, err
+	}
+	w.informer = cache.NewSharedIndexInformer(lw, objType, 0, cache.Indexers{})
+	w.informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		if isUnwatchable(err) {
+			_log.Infow("unable to watch", "key", key, "error", err)
+			wc.skip(key, w)
+			return
+		}
+		cache.DefaultWatchErrorHandler(r, err)
+	})
+	wc.watches[key] = w
+	go w.informer.Run(w.stop)
+	wc.m.Unlock()
+	return nil, false
+}
+
+// listLimited lists the objects in pages with list and fails as soon as
+// there are more than maxWatchObjects.
+func listLimited(list cache.ListFunc, options metav1.ListOptions) (runtime.Object, error) {
+	n := 0
+	p := pager.New(pager.SimplePageFunc(func(options metav1.ListOptions) (runtime.Object, error) {
+		l, err := list(options)
+		if err != nil {
+			return nil, err
+		}
+		if n += meta.LenList(l); n > maxWatchObjects {
+			return nil, errTooManyObjects
+		}
+		return l, nil
+	}))
+	p.PageSize = watchPageSize
+	// lists from the watch cache of the API server, resource version 0,
+	// are not paged
+	options.ResourceVersion = ""
+	l, _, err := p.List(context.TODO(), options)
+	return l, err
+}
+
+// isUnwatchable reports whether err prevents watching a resource type.
+func isUnwatchable(err error) bool {
+	return errors.Is(err, errTooManyObjects) || apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) ||
+		apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err)
+}
+
+// skip stops watch w of key and does not watch key again. It does nothing
+// if w was evicted, a newer watch of key may work.
+func (wc *watchCache) skip(key string, w *watch) {
+	wc.m.Lock()
+	defer wc.m.Unlock()
+	if wc.watches[key] != w {
+		return
+	}
+	close(w.stop)
+	delete(wc.watches, key)
+	wc.skipped[key] = true
+}
+
+// evictLocked stops the least recently used watch.
+func (wc *watchCache) evictLocked() {
+	var oldest string
+	for key, w := range wc.watches {
+		if oldest == "" || w.lastUsed.Before(wc.watches[oldest].lastUsed) {
+			oldest = key
+		}
+	}
+	if oldest == "" {
+		return
+	}
+	close(wc.watches[oldest].stop)
+	delete(wc.watches, oldest)
+}
+
+// close stops all watches.
+func (wc *watchCache) close() {
+	wc.m.Lock()
+	defer wc.m.Unlock()
+	for _, w := range wc.watches {
+		close(w.stop)
+	}
+	wc.closed = true
+	wc.watches = nil
+	wc.skipped = nil
+}
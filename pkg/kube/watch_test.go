@@ -0,0 +1,123 @@
+package kube
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+// eventually calls fn until it returns true, failing after a second.
+func eventually(t *testing.T, fn func() bool) {
+	t.Helper()
+	for start := time.Now(); !fn(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestWatchCache(t *testing.T) {
+	scheme := runtime.NewScheme()
+	metav1.AddMetaToScheme(scheme)
+	gvr := schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+	cert := func(namespace, name string) runtime.Object {
+		return &metav1.PartialObjectMetadata{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "cert-manager.io/v1", Kind: "Certificate"},
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		}
+	}
+	md := metadatafake.NewSimpleMetadataClient(scheme, cert("payments", "web-tls"), cert("payments", "api-tls"), cert("default", "other-tls"))
+	client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "payments", Name: "web-0"}})
+
+	wc := newWatchCache(client, md, 2)
+	defer wc.close()
+
+	// the first completion does not wait for the watch
+	if _, ok := wc.names(gvr, "payments"); ok {
+		t.Fatal("should not be synced yet")
+	}
+	var names []string
+	eventually(t, func() (ok bool) {
+		names, ok = wc.names(gvr, "payments")
+		return ok
+	})
+	if expected := []string{"api-tls", "web-tls"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("should be %v, got %v", expected, names)
+	}
+
+	var pods *corev1.PodList
+	eventually(t, func() (ok bool) {
+		pods, ok = wc.pods("payments")
+		return ok
+	})
+	if len(pods.Items) != 1 || pods.Items[0].Name != "web-0" {
+		t.Errorf("should be synced with pod web-0, got %v", pods)
+	}
+
+	// a third resource type evicts the least recently used one
+	eventually(t, func() bool {
+		_, ok := wc.names(gvr, "default")
+		return ok
+	})
+	wc.m.Lock()
+	_, watched := wc.watches[gvr.String()+"/payments"]
+	n := len(wc.watches)
+	wc.m.Unlock()
+	if watched || n != 2 {
+		t.Errorf("should evict the least recently used watch, watching %d, evicted %t", n, !watched)
+	}
+
+	wc.close()
+	if _, ok := wc.names(gvr, "payments"); ok {
+		t.Error("should not watch after close")
+	}
+}
+
+func TestWatchCacheTooManyObjects(t *testing.T) {
+	objs := make([]runtime.Object, maxWatchObjects+1)
+	for i := range objs {
+		objs[i] = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("web-%d", i)}}
+	}
+	wc := newWatchCache(fake.NewSimpleClientset(objs...), nil, 2)
+	defer wc.close()
+
+	wc.pods("default")
+	eventually(t, func() bool {
+		wc.m.Lock()
+		defer wc.m.Unlock()
+		return wc.skipped["pods/default"]
+	})
+	if _, ok := wc.pods("default"); ok {
+		t.Error("should not watch too many objects")
+	}
+}
+
+func TestWatchCacheSkipEvicted(t *testing.T) {
+	wc := newWatchCache(fake.NewSimpleClientset(), nil, 1)
+	defer wc.close()
+
+	wc.pods("default")
+	wc.m.Lock()
+	evicted := wc.watches["pods/default"]
+	wc.m.Unlock()
+	wc.pods("payments")
+	wc.pods("default")
+
+	// a late error of the evicted watch does not skip the newer one
+	wc.skip("pods/default", evicted)
+	wc.m.Lock()
+	_, watched := wc.watches["pods/default"]
+	skipped := wc.skipped["pods/default"]
+	wc.m.Unlock()
+	if !watched || skipped {
+		t.Errorf("should keep the newer watch, watched %t, skipped %t", watched, skipped)
+	}
+}
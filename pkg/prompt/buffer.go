@@ -14,8 +14,24 @@ type Buffer struct {
 	cacheDocument   *Document
 	preferredColumn int // Remember the original column for the next up/down movement.
 	lastKeyStroke   Key
+	// undo holds the states before the edits which can be undone, redo the
+	// states before the edits undone.
+	undo []bufferState
+	redo []bufferState
+	// insertEnd is the cursor position after text was inserted, -1 after
+	// other edits. Text inserted there is undone with the preceding text.
+	insertEnd int
 }
 
+// bufferState is the text and cursor of a buffer to return to by undo.
+type bufferState struct {
+	text   string
+	cursor int
+}
+
+// maxUndo limits the number of edits which can be undone.
+const maxUndo = 100
+
 // Text returns string of the current line.
 func (b *Buffer) Text() string {
 	return b.workingLines[b.workingIndex]
@@ -43,6 +59,10 @@ func (b *Buffer) DisplayCursorPosition() int {
 
 // InsertText insert string from current line.
 func (b *Buffer) InsertText(v string, overwrite bool, moveCursor bool) {
+	if v == "" {
+		return
+	}
+	b.saveUndo(!overwrite && !strings.HasPrefix(v, " "))
 	or := []rune(b.Text())
 	oc := b.cursorPosition
 
@@ -59,7 +79,52 @@ func (b *Buffer) InsertText(v string, overwrite bool, moveCursor bool) {
 
 	if moveCursor {
 		b.cursorPosition += len([]rune(v))
+		if !overwrite {
+			b.insertEnd = b.cursorPosition
+		}
+	}
+}
+
+// saveUndo saves the state before an edit. An insertion at the end of the
+// preceding one is undone together with it if join is true.
+func (b *Buffer) saveUndo(join bool) {
+	b.redo = nil
+	if join && b.insertEnd == b.cursorPosition && len(b.undo) > 0 {
+		return
+	}
+	b.insertEnd = -1
+	b.undo = append(b.undo, bufferState{text: b.Text(), cursor: b.cursorPosition})
+	if len(b.undo) > maxUndo {
+		b.undo = b.undo[len(b.undo)-maxUndo:]
+	}
+}
+
+// Undo reverts the last edit, it returns false if there is none.
+func (b *Buffer) Undo() bool {
+	if len(b.undo) == 0 {
+		return false
+	}
+	b.redo = append(b.redo, bufferState{text: b.Text(), cursor: b.cursorPosition})
+	b.restore(b.undo[len(b.undo)-1])
+	b.undo = b.undo[:len(b.undo)-1]
+	return true
+}
+
+// Redo applies the last edit reverted by Undo again, it returns false if
+// there is none. Edits after Undo discard what can be redone.
+func (b *Buffer) Redo() bool {
+	if len(b.redo) == 0 {
+		return false
 	}
+	b.undo = append(b.undo, bufferState{text: b.Text(), cursor: b.cursorPosition})
+	b.restore(b.redo[len(b.redo)-1])
+	b.redo = b.redo[:len(b.redo)-1]
+	return true
+}
+
+func (b *Buffer) restore(s bufferState) {
+	b.insertEnd = -1
+	b.setDocument(&Document{Text: s.text, cursorPosition: s.cursor})
 }
 
 // SetText method to set text and update cursorPosition.
@@ -128,7 +193,8 @@ func (b *Buffer) DeleteBeforeCursor(count int) (deleted string) {
 	debug.Assert(count >= 0, "count should be positive")
 	r := []rune(b.Text())
 
-	if b.cursorPosition > 0 {
+	if b.cursorPosition > 0 && count > 0 {
+		b.saveUndo(false)
 		start := b.cursorPosition - count
 		if start < 0 {
 			start = 0
@@ -154,9 +220,14 @@ func (b *Buffer) NewLine(copyMargin bool) {
 // Delete specified number of characters and Return the deleted text.
 func (b *Buffer) Delete(count int) (deleted string) {
 	r := []rune(b.Text())
-	if b.cursorPosition < len(r) {
-		deleted = b.Document().TextAfterCursor()[:count]
-		b.setText(string(r[:b.cursorPosition]) + string(r[b.cursorPosition+len(deleted):]))
+	if b.cursorPosition < len(r) && count > 0 {
+		b.saveUndo(false)
+		end := b.cursorPosition + count
+		if end > len(r) {
+			end = len(r)
+		}
+		deleted = string(r[b.cursorPosition:end])
+		b.setText(string(r[:b.cursorPosition]) + string(r[end:]))
 	}
 	return
 }
@@ -164,8 +235,10 @@ func (b *Buffer) Delete(count int) (deleted string) {
 // JoinNextLine joins the next line to the current one by deleting the line ending after the current line.
 func (b *Buffer) JoinNextLine(separator string) {
 	if !b.Document().OnLastLine() {
+		b.saveUndo(false)
 		b.cursorPosition += b.Document().GetEndOfLinePosition()
-		b.Delete(1)
+		r := []rune(b.Text())
+		b.setText(string(r[:b.cursorPosition]) + string(r[b.cursorPosition+1:]))
 		// Remove spaces
 		b.setText(b.Document().TextBeforeCursor() + separator + strings.TrimLeft(b.Document().TextAfterCursor(), " "))
 	}
@@ -174,6 +247,7 @@ func (b *Buffer) JoinNextLine(separator string) {
 // SwapCharactersBeforeCursor swaps the last two characters before the cursor.
 func (b *Buffer) SwapCharactersBeforeCursor() {
 	if b.cursorPosition >= 2 {
+		b.saveUndo(false)
 		x := b.Text()[b.cursorPosition-2 : b.cursorPosition-1]
 		y := b.Text()[b.cursorPosition-1 : b.cursorPosition]
 		b.setText(b.Text()[:b.cursorPosition-2] + y + x + b.Text()[b.cursorPosition:])
@@ -186,6 +260,15 @@ func NewBuffer() (b *Buffer) {
 		workingLines:    []string{""},
 		workingIndex:    0,
 		preferredColumn: -1, // -1 means nil
+		insertEnd:       -1,
 	}
 	return
 }
+
+// newTextBuffer returns a buffer holding text with the cursor at its end, the
+// text is not an edit which can be undone.
+func newTextBuffer(text string) *Buffer {
+	b := NewBuffer()
+	b.setDocument(&Document{Text: text, cursorPosition: len([]rune(text))})
+	return b
+}
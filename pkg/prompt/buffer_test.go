@@ -196,3 +196,57 @@ func TestBuffer_SwapCharactersBeforeCursor(t *testing.T) {
 		t.Errorf("Should be %#v, got %#v", ex, ac)
 	}
 }
+
+func TestBuffer_UndoRedo(t *testing.T) {
+	b := NewBuffer()
+	for _, s := range []string{"g", "et", " ", "p", "ods"} {
+		b.InsertText(s, false, true)
+	}
+	b.DeleteBeforeCursor(3)
+	b.CursorLeft(2)
+	b.Delete(10)
+
+	// typed text is undone word by word
+	want := []string{"get p", "get pods", "get", ""}
+	for _, w := range want {
+		if !b.Undo() {
+			t.Fatalf("nothing to undo before %q", w)
+		}
+		if b.Text() != w {
+			t.Errorf("Text should be %#v, got %#v", w, b.Text())
+		}
+	}
+	if b.Undo() {
+		t.Errorf("Undo should fail on the first state, got %#v", b.Text())
+	}
+
+	for _, w := range []string{"get", "get pods"} {
+		if !b.Redo() {
+			t.Fatalf("nothing to redo before %q", w)
+		}
+		if b.Text() != w {
+			t.Errorf("Text should be %#v, got %#v", w, b.Text())
+		}
+	}
+
+	// an edit drops the edits to redo
+	b.InsertText("x", false, true)
+	if b.Redo() {
+		t.Errorf("Redo should fail after an edit, got %#v", b.Text())
+	}
+	if b.Text() != "get podsx" || b.cursorPosition != len("get podsx") {
+		t.Errorf("Text should be %#v, got %#v at %d", "get podsx", b.Text(), b.cursorPosition)
+	}
+}
+
+func TestBuffer_DeleteMultiByte(t *testing.T) {
+	b := NewBuffer()
+	b.InsertText("日本語abc", false, true)
+	b.CursorLeft(5)
+	if got := b.Delete(10); got != "本語abc" {
+		t.Errorf("deleted should be %#v, got %#v", "本語abc", got)
+	}
+	if b.Text() != "日" {
+		t.Errorf("Text should be %#v, got %#v", "日", b.Text())
+	}
+}
@@ -0,0 +1,304 @@
+package prompt
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// escapeTimeout is how long the rest of a partial escape sequence is waited
+// for, before it is taken as typed, e.g. a single Escape.
+const escapeTimeout = 50 * time.Millisecond
+
+// Modifier is a set of modifier keys held while a key was pressed.
+type Modifier uint8
+
+// The bits of the modifiers are the ones of xterm, whose modifier parameter
+// is one more than the set.
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt
+	ModControl
+)
+
+// KeyEvent is a key or a run of text decoded from the input.
+type KeyEvent struct {
+	// Key is NotDefined for text and unknown sequences, BracketedPaste for
+	// pasted text.
+	Key Key
+	Mod Modifier
+	// Data holds the bytes of the key or text, the pasted text without the
+	// markers of a paste.
+	Data []byte
+}
+
+// Decoder splits a stream of input into key events. Sequences split across
+// chunks are completed by the next chunk, Flush ends a sequence which did not
+// complete in time.
+type Decoder struct {
+	pending []byte
+}
+
+// Decode returns the events of the chunk, an incomplete sequence at its end
+// is kept until the next chunk.
+func (d *Decoder) Decode(b []byte) []KeyEvent {
+	return d.decode(append(d.pending, b...), false)
+}
+
+// Flush returns the events of the incomplete sequence kept by Decode.
+func (d *Decoder) Flush() []KeyEvent {
+	return d.decode(d.pending, true)
+}
+
+// Pending returns true if an incomplete sequence is kept.
+func (d *Decoder) Pending() bool {
+	return len(d.pending) > 0
+}
+
+func (d *Decoder) decode(b []byte, flush bool) []KeyEvent {
+	var events []KeyEvent
+	for len(b) > 0 {
+		ev, n := decodeEvent(b, flush)
+		if n == 0 {
+			break
+		}
+		events = append(events, ev)
+		b = b[n:]
+	}
+	d.pending = append([]byte(nil), b...)
+	return events
+}
+
+var sequenceKeys, sequencePrefixes, maxSequenceLen = indexSequences()
+
+// indexSequences returns the keys of ASCIISequences by their sequence, the
+// first key of a sequence wins like in GetKey, and the proper prefixes of
+// the sequences.
+func indexSequences() (map[string]Key, map[string]bool, int) {
+	keys := map[string]Key{}
+	prefixes := map[string]bool{}
+	max := 0
+	for _, s := range ASCIISequences {
+		if _, ok := keys[string(s.ASCIICode)]; !ok {
+			keys[string(s.ASCIICode)] = s.Key
+		}
+		for i := 1; i < len(s.ASCIICode); i++ {
+			prefixes[string(s.ASCIICode[:i])] = true
+		}
+		if len(s.ASCIICode) > max {
+			max = len(s.ASCIICode)
+		}
+	}
+	return keys, prefixes, max
+}
+
+// decodeEvent returns the first event of b and its length, 0 if more input
+// is needed to decode it.
+func decodeEvent(b []byte, flush bool) (KeyEvent, int) {
+	switch c := b[0]; {
+	case c == 0x1b:
+		return decodeEscape(b, flush)
+	case isControl(c):
+		return KeyEvent{Key: controlKey(c), Data: b[:1]}, 1
+	default:
+		return decodeText(b, flush)
+	}
+}
+
+func isControl(c byte) bool {
+	return c < ' ' || c == 0x7f
+}
+
+func controlKey(c byte) Key {
+	if k, ok := sequenceKeys[string([]byte{c})]; ok {
+		return k
+	}
+	return NotDefined
+}
+
+// decodeText returns the run of text at the start of b.
+func decodeText(b []byte, flush bool) (KeyEvent, int) {
+	n := 0
+	for n < len(b) {
+		c := b[n]
+		if isControl(c) || c == 0x1b {
+			break
+		}
+		if c < utf8.RuneSelf {
+			n++
+			continue
+		}
+		if !flush && !utf8.FullRune(b[n:]) {
+			break
+		}
+		_, size := utf8.DecodeRune(b[n:])
+		n += size
+	}
+	if n == 0 {
+		return KeyEvent{}, 0
+	}
+	return KeyEvent{Key: NotDefined, Data: b[:n]}, n
+}
+
+// decodeEscape decodes the sequence starting with escape at the start of b.
+func decodeEscape(b []byte, flush bool) (KeyEvent, int) {
+	if !flush && len(b) < maxSequenceLen && sequencePrefixes[string(b)] {
+		return KeyEvent{}, 0
+	}
+	if n, key := longestSequence(b); n > 1 {
+		return KeyEvent{Key: key, Mod: csiModifier(b[:n]), Data: b[:n]}, n
+	}
+	if len(b) == 1 {
+		return KeyEvent{Key: Escape, Data: b[:1]}, 1
+	}
+
+	switch b[1] {
+	case '[':
+		end, ok := csiEnd(b)
+		if !ok {
+			if !flush {
+				return KeyEvent{}, 0
+			}
+			return decodeAlt(b, flush)
+		}
+		if bytes.Equal(b[:end], pasteStart) {
+			return decodePaste(b, flush)
+		}
+		return decodeCSI(b[:end]), end
+	case 'O':
+		if len(b) < 3 {
+			if !flush {
+				return KeyEvent{}, 0
+			}
+			return decodeAlt(b, flush)
+		}
+		return KeyEvent{Key: NotDefined, Data: b[:3]}, 3
+	case 0x1b:
+		return KeyEvent{Key: Escape, Data: b[:1]}, 1
+	default:
+		return decodeAlt(b, flush)
+	}
+}
+
+// longestSequence returns the length and key of the longest sequence of
+// ASCIISequences at the start of b.
+func longestSequence(b []byte) (int, Key) {
+	for n := maxSequenceLen; n > 0; n-- {
+		if n > len(b) {
+			continue
+		}
+		if k, ok := sequenceKeys[string(b[:n])]; ok {
+			return n, k
+		}
+	}
+	return 0, NotDefined
+}
+
+// decodeAlt decodes escape followed by a key as the key pressed with Alt.
+func decodeAlt(b []byte, flush bool) (KeyEvent, int) {
+	if isControl(b[1]) {
+		return KeyEvent{Key: controlKey(b[1]), Mod: ModAlt, Data: b[:2]}, 2
+	}
+	if !flush && !utf8.FullRune(b[1:]) {
+		return KeyEvent{}, 0
+	}
+	_, size := utf8.DecodeRune(b[1:])
+	return KeyEvent{Key: NotDefined, Mod: ModAlt, Data: b[:1+size]}, 1 + size
+}
+
+// csiEnd returns the length of the control sequence at the start of b, false
+// if it is incomplete. A malformed sequence ends before the first byte
+// which does not belong to it.
+func csiEnd(b []byte) (int, bool) {
+	for i := 2; i < len(b); i++ {
+		switch c := b[i]; {
+		case c >= 0x20 && c <= 0x3f:
+			// parameter and intermediate bytes
+		case c >= 0x40 && c <= 0x7e:
+			return i + 1, true
+		default:
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// csiParams returns the parameters and the final byte of a control sequence.
+func csiParams(seq []byte) ([]string, byte, bool) {
+	if len(seq) < 3 || seq[0] != 0x1b || seq[1] != '[' {
+		return nil, 0, false
+	}
+	return strings.Split(string(seq[2:len(seq)-1]), ";"), seq[len(seq)-1], true
+}
+
+// csiModifier returns the modifiers of a control sequence such as ESC[1;5A.
+func csiModifier(seq []byte) Modifier {
+	params, _, ok := csiParams(seq)
+	if !ok || len(params) != 2 {
+		return 0
+	}
+	m, err := strconv.Atoi(params[1])
+	if err != nil || m < 2 {
+		return 0
+	}
+	return Modifier(m-1) & (ModShift | ModAlt | ModControl)
+}
+
+var (
+	csiFinalKeys = map[byte]Key{
+		'A': Up, 'B': Down, 'C': Right, 'D': Left,
+		'H': Home, 'F': End,
+		'P': F1, 'Q': F2, 'R': F3, 'S': F4,
+	}
+	csiTildeKeys = map[string]Key{
+		"1": Home, "2": Insert, "3": Delete, "4": End,
+		"5": PageUp, "6": PageDown, "7": Home, "8": End,
+		"15": F5, "17": F6, "18": F7, "19": F8, "20": F9, "21": F10,
+		"23": F11, "24": F12,
+	}
+	controlKeys = map[Key]Key{
+		Up: ControlUp, Down: ControlDown, Right: ControlRight, Left: ControlLeft,
+		Delete: ControlDelete,
+	}
+	shiftKeys = map[Key]Key{
+		Up: ShiftUp, Down: ShiftDown, Right: ShiftRight, Left: ShiftLeft,
+		Delete: ShiftDelete,
+	}
+)
+
+// decodeCSI decodes a control sequence which is not in ASCIISequences, e.g.
+// an arrow key with modifiers.
+func decodeCSI(seq []byte) KeyEvent {
+	ev := KeyEvent{Key: NotDefined, Mod: csiModifier(seq), Data: seq}
+	params, final, _ := csiParams(seq)
+	key, ok := csiFinalKeys[final]
+	if final == '~' {
+		key, ok = csiTildeKeys[params[0]]
+	}
+	if !ok {
+		return ev
+	}
+
+	ev.Key = key
+	if k, ok := controlKeys[key]; ok && ev.Mod&ModControl != 0 {
+		ev.Key = k
+	} else if k, ok := shiftKeys[key]; ok && ev.Mod&ModShift != 0 {
+		ev.Key = k
+	}
+	return ev
+}
+
+// decodePaste decodes a bracketed paste at the start of b.
+func decodePaste(b []byte, flush bool) (KeyEvent, int) {
+	start := len(pasteStart)
+	i := bytes.Index(b[start:], pasteEnd)
+	if i < 0 {
+		if !flush {
+			return KeyEvent{}, 0
+		}
+		return KeyEvent{Key: BracketedPaste, Data: b[start:]}, len(b)
+	}
+	return KeyEvent{Key: BracketedPaste, Data: b[start : start+i]}, start + i + len(pasteEnd)
+}
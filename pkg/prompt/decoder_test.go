@@ -0,0 +1,201 @@
+package prompt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []KeyEvent
+		// pending is true if an incomplete sequence is kept at the end.
+		pending bool
+	}{
+		{
+			name:   "text and keys",
+			chunks: []string{"get\x1b[Dpo\r"},
+			want: []KeyEvent{
+				{Key: NotDefined, Data: []byte("get")},
+				{Key: Left, Data: []byte("\x1b[D")},
+				{Key: NotDefined, Data: []byte("po")},
+				{Key: ControlM, Data: []byte("\r")},
+			},
+		},
+		{
+			name:   "sequence split across chunks",
+			chunks: []string{"a\x1b[", "1;5", "Cb"},
+			want: []KeyEvent{
+				{Key: NotDefined, Data: []byte("a")},
+				{Key: ControlRight, Mod: ModControl, Data: []byte("\x1b[1;5C")},
+				{Key: NotDefined, Data: []byte("b")},
+			},
+		},
+		{
+			name:   "modifiers",
+			chunks: []string{"\x1b[1;3A\x1b[1;2D\x1b[1;6B\x1b[3;5~\x1b[5;3~"},
+			want: []KeyEvent{
+				{Key: Up, Mod: ModAlt, Data: []byte("\x1b[1;3A")},
+				{Key: ShiftLeft, Mod: ModShift, Data: []byte("\x1b[1;2D")},
+				{Key: ControlDown, Mod: ModShift | ModControl, Data: []byte("\x1b[1;6B")},
+				{Key: ControlDelete, Mod: ModControl, Data: []byte("\x1b[3;5~")},
+				{Key: PageUp, Mod: ModAlt, Data: []byte("\x1b[5;3~")},
+			},
+		},
+		{
+			name:   "alt",
+			chunks: []string{"\x1bb\x1b\x7f\x1bé"},
+			want: []KeyEvent{
+				{Key: NotDefined, Mod: ModAlt, Data: []byte("\x1bb")},
+				{Key: Backspace, Mod: ModAlt, Data: []byte("\x1b\x7f")},
+				{Key: NotDefined, Mod: ModAlt, Data: []byte("\x1bé")},
+			},
+		},
+		{
+			name:    "escape waits",
+			chunks:  []string{"\x1b"},
+			pending: true,
+		},
+		{
+			name:   "utf-8 split across chunks",
+			chunks: []string{"caf\xc3", "\xa9"},
+			want: []KeyEvent{
+				{Key: NotDefined, Data: []byte("caf")},
+				{Key: NotDefined, Data: []byte("é")},
+			},
+		},
+		{
+			name:   "paste",
+			chunks: []string{"\x1b[200~get\x1b[A\r", "\n\x1b[201~x"},
+			want: []KeyEvent{
+				{Key: BracketedPaste, Data: []byte("get\x1b[A\r\n")},
+				{Key: NotDefined, Data: []byte("x")},
+			},
+		},
+		{
+			name:   "unknown sequence",
+			chunks: []string{"\x1b[99za"},
+			want: []KeyEvent{
+				{Key: NotDefined, Data: []byte("\x1b[99z")},
+				{Key: NotDefined, Data: []byte("a")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decoder
+			var got []KeyEvent
+			for _, c := range tt.chunks {
+				got = append(got, d.Decode([]byte(c))...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+			if d.Pending() != tt.pending {
+				t.Errorf("pending = %t, want %t", d.Pending(), tt.pending)
+			}
+		})
+	}
+}
+
+func TestDecoderFlush(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []KeyEvent
+	}{
+		{
+			name: "escape",
+			in:   "\x1b",
+			want: []KeyEvent{{Key: Escape, Data: []byte("\x1b")}},
+		},
+		{
+			name: "incomplete sequence",
+			in:   "\x1b[1;",
+			want: []KeyEvent{
+				{Key: NotDefined, Mod: ModAlt, Data: []byte("\x1b[")},
+				{Key: NotDefined, Data: []byte("1;")},
+			},
+		},
+		{
+			name: "incomplete paste",
+			in:   "\x1b[200~get",
+			want: []KeyEvent{{Key: BracketedPaste, Data: []byte("get")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Decoder
+			if got := d.Decode([]byte(tt.in)); len(got) != 0 {
+				t.Fatalf("decoded %+v before flush", got)
+			}
+			if got := d.Flush(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+			if d.Pending() {
+				t.Error("sequence is still pending after flush")
+			}
+		})
+	}
+}
+
+// mergeText joins adjacent runs of text, which are split where the input
+// was split.
+func mergeText(events []KeyEvent) []KeyEvent {
+	var merged []KeyEvent
+	for _, ev := range events {
+		ev.Data = append([]byte(nil), ev.Data...)
+		if n := len(merged); n > 0 && isText(ev) && isText(merged[n-1]) {
+			merged[n-1].Data = append(merged[n-1].Data, ev.Data...)
+			continue
+		}
+		merged = append(merged, ev)
+	}
+	return merged
+}
+
+func isText(ev KeyEvent) bool {
+	return ev.Key == NotDefined && ev.Mod == 0 && len(ev.Data) > 0 && ev.Data[0] != 0x1b && !isControl(ev.Data[0])
+}
+
+func FuzzDecoder(f *testing.F) {
+	for _, s := range []string{
+		"get pods\r",
+		"\x1b[A\x1b[1;5C\x1bb",
+		"\x1b[200~a\tb\r\n\x1b[201~",
+		"\x1b[24~\x08\x1bOP\x1b[[A",
+		"caf\xc3\xa9 \xff\x1b",
+	} {
+		f.Add([]byte(s), uint(len(s)/2))
+	}
+
+	f.Fuzz(func(t *testing.T, in []byte, split uint) {
+		var whole Decoder
+		want := mergeText(append(whole.Decode(in), whole.Flush()...))
+
+		i := int(split % uint(len(in)+1))
+		var d Decoder
+		got := d.Decode(in[:i])
+		got = append(got, d.Decode(in[i:])...)
+		got = mergeText(append(got, d.Flush()...))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("split at %d: events = %+v, want %+v", i, got, want)
+		}
+		if d.Pending() {
+			t.Error("sequence is still pending after flush")
+		}
+
+		if bytes.Contains(in, pasteStart) {
+			return
+		}
+		var data []byte
+		for _, ev := range want {
+			data = append(data, ev.Data...)
+		}
+		if !bytes.Equal(data, in) {
+			t.Errorf("events hold %q, want %q", data, in)
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package prompt
 
-import "github.com/paralus/prompt/pkg/prompt/internal/debug"
+import (
+	"bytes"
+
+	"github.com/paralus/prompt/pkg/prompt/internal/debug"
+)
 
 /*
 
@@ -15,9 +19,12 @@ Moving the cursor
 * [x] Ctrl + e   Go to the End of the line (End)
 * [x] Ctrl + p   Previous command (Up arrow)
 * [x] Ctrl + n   Next command (Down arrow)
+* [x] Ctrl + r   Search the history backwards (Esc or Ctrl + g to cancel)
 * [x] Ctrl + f   Forward one character
 * [x] Ctrl + b   Backward one character
 * [x] Ctrl + xx  Toggle between the start of line and current cursor position
+* [x] Alt  + f   Forward one word
+* [x] Alt  + b   Backward one word
 
 Editing
 -------
@@ -29,12 +36,15 @@ Editing
 * [x] Ctrl + w   Cut the Word before the cursor to the clipboard.
 * [x] Ctrl + k   Cut the Line after the cursor to the clipboard.
 * [x] Ctrl + u   Cut/delete the Line before the cursor to the clipboard.
+* [x] Alt  + d   Cut the Word after the cursor to the clipboard.
 
 * [ ] Ctrl + t   Swap the last two characters before the cursor (typo).
 * [ ] Esc  + t   Swap the last two words before the cursor.
 
-* [ ] ctrl + y   Paste the last thing to be cut (yank)
-* [ ] ctrl + _   Undo
+* [x] ctrl + y   Paste the last thing to be cut (yank)
+* [x] Alt  + y   Replace the text pasted by the thing cut before it
+* [x] ctrl + _   Undo (Ctrl + x Ctrl + u)
+* [x] Alt  + _   Redo
 
 */
 
@@ -55,22 +65,6 @@ var emacsKeyBindings = []KeyBind{
 			buf.CursorLeft(len(x))
 		},
 	},
-	// Cut the Line after the cursor
-	{
-		Key: ControlK,
-		Fn: func(buf *Buffer) {
-			x := []rune(buf.Document().TextAfterCursor())
-			buf.Delete(len(x))
-		},
-	},
-	// Cut/delete the Line before the cursor
-	{
-		Key: ControlU,
-		Fn: func(buf *Buffer) {
-			x := []rune(buf.Document().TextBeforeCursor())
-			buf.DeleteBeforeCursor(len(x))
-		},
-	},
 	// Delete character under the cursor
 	{
 		Key: ControlD,
@@ -101,13 +95,6 @@ var emacsKeyBindings = []KeyBind{
 			buf.CursorLeft(1)
 		},
 	},
-	// Cut the Word before the cursor.
-	{
-		Key: ControlW,
-		Fn: func(buf *Buffer) {
-			buf.DeleteBeforeCursor(len([]rune(buf.Document().GetWordBeforeCursorWithSpace())))
-		},
-	},
 	// Clear the Screen, similar to the clear command
 	{
 		Key: ControlL,
@@ -118,3 +105,71 @@ var emacsKeyBindings = []KeyBind{
 		},
 	},
 }
+
+var emacsASCIICodeBindings = []ASCIICodeBind{
+	// Forward one word
+	{
+		ASCIICode: []byte{0x1b, 'f'},
+		Fn:        GoRightWord,
+	},
+	// Backward one word
+	{
+		ASCIICode: []byte{0x1b, 'b'},
+		Fn:        GoLeftWord,
+	},
+	// Redo
+	{
+		ASCIICode: []byte{0x1b, '_'},
+		Fn: func(buf *Buffer) {
+			buf.Redo()
+		},
+	},
+}
+
+// feedEmacs handles the emacs keys which cut text into the kill ring, yank
+// it back and undo. It returns true if the key was consumed as part of the
+// Ctrl-X prefix, otherwise it is handled as usual too.
+func (p *Prompt) feedEmacs(ev KeyEvent) bool {
+	k := &p.kills
+	command := killNone
+	defer func() {
+		if command == killNone {
+			k.last = killNone
+		}
+	}()
+
+	if p.ctrlX {
+		p.ctrlX = false
+		if ev.Key == ControlU {
+			p.buf.Undo()
+			return true
+		}
+	}
+
+	switch {
+	case ev.Key == ControlX:
+		p.ctrlX = true
+		return true
+	case ev.Key == ControlUnderscore:
+		p.buf.Undo()
+	case ev.Key == ControlK:
+		command = killForward
+		k.kill(p.buf.Delete(len([]rune(p.buf.Document().TextAfterCursor()))), command)
+	case ev.Key == ControlU:
+		command = killBackward
+		k.kill(p.buf.DeleteBeforeCursor(len([]rune(p.buf.Document().TextBeforeCursor()))), command)
+	case ev.Key == ControlW:
+		command = killBackward
+		k.kill(p.buf.DeleteBeforeCursor(len([]rune(p.buf.Document().GetWordBeforeCursorWithSpace()))), command)
+	case bytes.Equal(ev.Data, []byte{0x1b, 'd'}):
+		command = killForward
+		k.kill(p.buf.Delete(p.buf.Document().FindEndOfCurrentWordWithSpace()), command)
+	case ev.Key == ControlY:
+		command = killYank
+		k.yank(p.buf)
+	case bytes.Equal(ev.Data, []byte{0x1b, 'y'}):
+		command = killYank
+		k.yankPop(p.buf)
+	}
+	return false
+}
@@ -30,3 +30,76 @@ func applyEmacsKeyBind(buf *Buffer, key Key) {
 		}
 	}
 }
+
+func TestEmacsKillRing(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string
+		want   string
+		cursor int
+	}{
+		{
+			name:   "kill line and yank",
+			keys:   []string{"\x01", "\x0b", "\x19", "\x19"},
+			want:   "get pods -n kube-systemget pods -n kube-system",
+			cursor: 46,
+		},
+		{
+			name:   "consecutive kills are joined",
+			keys:   []string{"\x17", "\x17", "\x01", "\x19"},
+			want:   "-n kube-systemget pods ",
+			cursor: 14,
+		},
+		{
+			name:   "cycle with alt-y",
+			keys:   []string{"\x17", "\x02", "\x17", "\x01", "\x19", "\x1by"},
+			want:   "kube-systemget pods  ",
+			cursor: 11,
+		},
+		{
+			name:   "alt-y without yank",
+			keys:   []string{"\x17", "\x1by"},
+			want:   "get pods -n ",
+			cursor: 12,
+		},
+		{
+			name:   "kill word after cursor",
+			keys:   []string{"\x01", "\x1bd", "\x1bd", "\x05", "\x19"},
+			want:   " -n kube-systemget pods",
+			cursor: 23,
+		},
+		{
+			name:   "word motions",
+			keys:   []string{"\x1bb", "\x1bb", "\x1bf", "x"},
+			want:   "get pods -nx kube-system",
+			cursor: 12,
+		},
+		{
+			name:   "undo",
+			keys:   []string{"\x15", "x", "\x1f", "\x1f"},
+			want:   "get pods -n kube-system",
+			cursor: 23,
+		},
+		{
+			name:   "undo with ctrl-x and redo",
+			keys:   []string{"\x17", "\x18", "\x15", "\x1b_"},
+			want:   "get pods -n ",
+			cursor: 12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newPastePrompt()
+			p.feed([]byte("get pods -n kube-system"))
+			for _, k := range tt.keys {
+				p.feed([]byte(k))
+			}
+			if got := p.buf.Text(); got != tt.want {
+				t.Errorf("buffer = %q, want %q", got, tt.want)
+			}
+			if got := p.buf.cursorPosition; got != tt.cursor {
+				t.Errorf("cursor = %d, want %d", got, tt.cursor)
+			}
+		})
+	}
+}
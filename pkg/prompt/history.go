@@ -1,16 +1,30 @@
 package prompt
 
+// HistoryStore persists the texts that are entered, e.g. across sessions.
+type HistoryStore interface {
+	// Load returns the stored texts, oldest first.
+	Load() ([]string, error)
+	// Append stores an entered text.
+	Append(input string) error
+}
+
 // History stores the texts that are entered.
 type History struct {
 	histories []string
 	tmp       []string
 	selected  int
+	store     HistoryStore
 }
 
 // Add to add text in history.
 func (h *History) Add(input string) {
 	h.histories = append(h.histories, input)
 	h.Clear()
+	if h.store != nil {
+		if err := h.store.Append(input); err != nil {
+			_log.Infow("unable to store history", "error", err)
+		}
+	}
 }
 
 // Clear to clear the history.
@@ -32,9 +46,7 @@ func (h *History) Older(buf *Buffer) (new *Buffer, changed bool) {
 	h.tmp[h.selected] = buf.Text()
 
 	h.selected--
-	new = NewBuffer()
-	new.InsertText(h.tmp[h.selected], false, true)
-	return new, true
+	return newTextBuffer(h.tmp[h.selected]), true
 }
 
 // Newer saves a buffer of current line and get a buffer of next line by up-arrow.
@@ -46,9 +58,7 @@ func (h *History) Newer(buf *Buffer) (new *Buffer, changed bool) {
 	h.tmp[h.selected] = buf.Text()
 
 	h.selected++
-	new = NewBuffer()
-	new.InsertText(h.tmp[h.selected], false, true)
-	return new, true
+	return newTextBuffer(h.tmp[h.selected]), true
 }
 
 // NewHistory returns new history object.
@@ -60,3 +60,38 @@ func TestHistoryOlder(t *testing.T) {
 		t.Errorf("Should be %#v, but got %#v", "echo 1", buf2.Text())
 	}
 }
+
+type testHistoryStore struct {
+	entries []string
+}
+
+func (s *testHistoryStore) Load() ([]string, error) {
+	return s.entries, nil
+}
+
+func (s *testHistoryStore) Append(input string) error {
+	s.entries = append(s.entries, input)
+	return nil
+}
+
+func TestHistoryStore(t *testing.T) {
+	store := &testHistoryStore{entries: []string{"get pods"}}
+	p := &Prompt{history: NewHistory()}
+	entries, _ := store.Load()
+	for _, opt := range []Option{OptionHistory(entries), OptionHistoryStore(store)} {
+		if err := opt(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p.history.Add("get nodes")
+	expected := []string{"get pods", "get nodes"}
+	if !reflect.DeepEqual(store.entries, expected) {
+		t.Errorf("Should be %#v, but got %#v", expected, store.entries)
+	}
+
+	buf, changed := p.history.Older(NewBuffer())
+	if !changed || buf.Text() != "get nodes" {
+		t.Errorf("Should be %#v, but got %#v", "get nodes", buf.Text())
+	}
+}
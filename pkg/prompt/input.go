@@ -1,6 +1,6 @@
 package prompt
 
-import "bytes"
+import "context"
 
 // WinSize represents the width and height of terminal.
 type WinSize struct {
@@ -20,12 +20,28 @@ type ConsoleParser interface {
 	Read() ([]byte, error)
 }
 
+// ContextParser is a ConsoleParser whose reads can be cancelled, so that the
+// prompt stops reading before the executor reads the input.
+type ContextParser interface {
+	ConsoleParser
+	// ReadContext returns byte array like Read, unless ctx is done first.
+	ReadContext(ctx context.Context) ([]byte, error)
+}
+
+// ResizableParser is a ConsoleParser whose window size is pushed by the caller
+// instead of being queried from a tty, e.g. a terminal attached over a websocket.
+type ResizableParser interface {
+	ConsoleParser
+	// SetWinSize updates the window size and notifies the prompt.
+	SetWinSize(ws *WinSize)
+	// WinSizeChanged returns a channel which receives the latest window size.
+	WinSizeChanged() <-chan *WinSize
+}
+
 // GetKey returns Key correspond to input byte codes.
 func GetKey(b []byte) Key {
-	for _, k := range ASCIISequences {
-		if bytes.Equal(k.ASCIICode, b) {
-			return k.Key
-		}
+	if k, ok := sequenceKeys[string(b)]; ok {
+		return k
 	}
 	return NotDefined
 }
@@ -1,63 +1,117 @@
 package prompt
 
 import (
-	"bytes"
+	"context"
 	"io"
-
-	"github.com/mxk/go-flowrate/flowrate"
+	"sync"
 )
 
+// contextReader is a reader whose reads can be cancelled, e.g. a terminal
+// attached over a websocket.
+type contextReader interface {
+	ReadContext(ctx context.Context, p []byte) (int, error)
+}
+
 type ioParser struct {
+	m          sync.RWMutex
 	rows, cols uint16
-	r          *flowrate.Reader
+	r          io.Reader
+	winSizeCh  chan *WinSize
+	paste      pasteReader
+	// inflight is a read of r outlasting its context, if r is no
+	// contextReader.
+	inflight backgroundRead
 }
 
 // Setup should be called before starting input
 func (p *ioParser) Setup() error {
-	_log.Debugw("setting up ioParser")
-	p.r.SetBlocking(true)
 	return nil
 }
 
 // TearDown should be called after stopping input
 func (p *ioParser) TearDown() error {
-	_log.Debugw("tearding down ioParser")
-	p.r.SetBlocking(false)
 	return nil
 }
 
 // GetKey returns Key correspond to input byte codes.
 func (p *ioParser) GetKey(b []byte) Key {
-	for _, k := range ASCIISequences {
-		if bytes.Equal(k.ASCIICode, b) {
-			return k.Key
-		}
-	}
-	return NotDefined
+	return GetKey(b)
 }
 
 // GetWinSize returns WinSize object to represent width and height of terminal.
 func (p *ioParser) GetWinSize() *WinSize {
+	p.m.RLock()
+	defer p.m.RUnlock()
 	return &WinSize{Col: p.cols, Row: p.rows}
 }
 
-// Read returns byte array.
-func (p *ioParser) Read() (b []byte, err error) {
-	b = make([]byte, 1024)
-	n, err := p.r.Read(b)
+// SetWinSize updates the window size and notifies the prompt.
+func (p *ioParser) SetWinSize(ws *WinSize) {
+	if ws == nil || ws.Row == 0 || ws.Col == 0 {
+		return
+	}
+
+	p.m.Lock()
+	p.rows, p.cols = ws.Row, ws.Col
+	p.m.Unlock()
+
+	// only the latest size matters, drop a pending one the prompt has not seen yet.
+	select {
+	case <-p.winSizeCh:
+	default:
+	}
+	select {
+	case p.winSizeCh <- &WinSize{Row: ws.Row, Col: ws.Col}:
+	default:
+	}
+}
+
+// WinSizeChanged returns a channel which receives the latest window size.
+func (p *ioParser) WinSizeChanged() <-chan *WinSize {
+	return p.winSizeCh
+}
+
+// Read returns byte array, a bracketed paste is returned as a whole.
+func (p *ioParser) Read() ([]byte, error) {
+	return p.ReadContext(context.Background())
+}
+
+// ReadContext returns byte array like Read, unless ctx is done first.
+func (p *ioParser) ReadContext(ctx context.Context) ([]byte, error) {
+	return p.paste.Read(ctx)
+}
+
+func (p *ioParser) read(ctx context.Context) ([]byte, error) {
+	cr, ok := p.r.(contextReader)
+	if !ok {
+		return p.inflight.read(ctx, p.readChunk)
+	}
+	b := make([]byte, 1024)
+	n, err := cr.ReadContext(ctx, b)
 	if err != nil {
 		return nil, err
 	}
-	return b[0:n], nil
-
+	return b[:n], nil
 }
 
-var _ ConsoleParser = (*ioParser)(nil)
+func (p *ioParser) readChunk() ([]byte, error) {
+	b := make([]byte, 1024)
+	n, err := p.r.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	return b[:n], nil
+}
 
-// NewIOParser returns a console parser backed by io.Reader
-func NewIOParser(rows, cols uint16, r io.Reader) ConsoleParser {
-	frr := flowrate.NewReader(r, 1<<20)
-	frr.SetBlocking(true)
+var (
+	_ ResizableParser = (*ioParser)(nil)
+	_ ContextParser   = (*ioParser)(nil)
+)
 
-	return &ioParser{rows: rows, cols: cols, r: frr}
+// NewIOParser returns a console parser backed by io.Reader, reads are
+// cancelled if r has a ReadContext method like ContextParser.
+func NewIOParser(rows, cols uint16, r io.Reader) ResizableParser {
+	p := &ioParser{rows: rows, cols: cols, r: r, winSizeCh: make(chan *WinSize, 1)}
+	p.paste.read = p.read
+	return p
 }
@@ -4,6 +4,7 @@
 package prompt
 
 import (
+	"context"
 	"syscall"
 
 	"github.com/paralus/prompt/pkg/prompt/internal/term"
@@ -16,6 +17,7 @@ const maxReadBytes = 1024
 type PosixParser struct {
 	fd          int
 	origTermios syscall.Termios
+	paste       pasteReader
 }
 
 func (t *PosixParser) Close() error {
@@ -45,8 +47,12 @@ func (t *PosixParser) TearDown() error {
 	return nil
 }
 
-// Read returns byte array.
+// Read returns byte array, a bracketed paste is returned as a whole.
 func (t *PosixParser) Read() ([]byte, error) {
+	return t.paste.Read(context.Background())
+}
+
+func (t *PosixParser) read(context.Context) ([]byte, error) {
 	buf := make([]byte, maxReadBytes)
 	n, err := syscall.Read(t.fd, buf)
 	if err != nil {
@@ -76,7 +82,9 @@ func NewStandardInputParser() *PosixParser {
 		panic(err)
 	}
 
-	return &PosixParser{
+	p := &PosixParser{
 		fd: in,
 	}
+	p.paste.read = p.read
+	return p
 }
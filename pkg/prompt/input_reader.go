@@ -0,0 +1,151 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+)
+
+// reader reads the input of the prompt and decodes it into key events,
+// until it is stopped to hand the input over to the executor. It does not
+// read ahead: the next chunk is only read once the events of the previous
+// one were fed, so input typed for the executor is left to it.
+type reader struct {
+	// events receives the events of every chunk read, it is closed once
+	// reading failed with err.
+	events chan []KeyEvent
+	next   chan struct{}
+	err    error
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startReading starts reading the input until ctx is done or the reader is
+// stopped.
+func (p *Prompt) startReading(ctx context.Context) *reader {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &reader{
+		events: make(chan []KeyEvent),
+		next:   make(chan struct{}, 1),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	r.more()
+	go p.read(ctx, r)
+	return r
+}
+
+// more asks for the events of the next chunk.
+func (r *reader) more() {
+	select {
+	case r.next <- struct{}{}:
+	default:
+	}
+}
+
+// stop stops reading and waits until the reader returned, so that nothing
+// is read from the input afterwards.
+func (r *reader) stop() {
+	r.cancel()
+	<-r.done
+}
+
+func (p *Prompt) read(ctx context.Context, r *reader) {
+	defer close(r.done)
+	_log.Debugw("start reading input")
+
+	for {
+		select {
+		case <-r.next:
+		case <-ctx.Done():
+			_log.Debugw("stop reading input")
+			return
+		}
+
+		events := p.unread
+		p.unread = nil
+		if len(events) == 0 {
+			var err error
+			events, err = p.readEvents(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					_log.Debugw("stop reading input")
+					return
+				}
+				r.err = err
+				close(r.events)
+				return
+			}
+		}
+		if len(events) == 0 {
+			r.more()
+			continue
+		}
+
+		select {
+		case r.events <- events:
+		case <-ctx.Done():
+			p.unread = events
+			_log.Debugw("stop reading input")
+			return
+		}
+	}
+}
+
+// readEvents reads the next chunk of input and decodes it. A partial
+// escape sequence is flushed if the rest does not follow in time.
+func (p *Prompt) readEvents(ctx context.Context) ([]KeyEvent, error) {
+	rctx := ctx
+	if p.decoder.Pending() {
+		var cancel context.CancelFunc
+		rctx, cancel = context.WithTimeout(ctx, escapeTimeout)
+		defer cancel()
+	}
+
+	b, err := p.readInput(rctx)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return p.decoder.Flush(), nil
+		}
+		return nil, err
+	}
+	return p.decoder.Decode(b), nil
+}
+
+// readInput reads from the parser, unless ctx is done first.
+func (p *Prompt) readInput(ctx context.Context) ([]byte, error) {
+	if cp, ok := p.in.(ContextParser); ok {
+		return cp.ReadContext(ctx)
+	}
+	return p.inflight.read(ctx, p.in.Read)
+}
+
+type readResult struct {
+	b   []byte
+	err error
+}
+
+// backgroundRead runs reads which can not be cancelled in a goroutine, so
+// that waiting for them can be. The result of a read which outlasted its
+// context is returned by the next read.
+type backgroundRead struct {
+	pending chan readResult
+}
+
+func (r *backgroundRead) read(ctx context.Context, read func() ([]byte, error)) ([]byte, error) {
+	if r.pending == nil {
+		ch := make(chan readResult, 1)
+		go func() {
+			b, err := read()
+			ch <- readResult{b: b, err: err}
+		}()
+		r.pending = ch
+	}
+
+	select {
+	case res := <-r.pending:
+		r.pending = nil
+		return res.b, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
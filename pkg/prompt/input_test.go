@@ -1,6 +1,8 @@
 package prompt
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -31,3 +33,31 @@ func TestPosixParserGetKey(t *testing.T) {
 		})
 	}
 }
+
+func TestIOParserSetWinSize(t *testing.T) {
+	p := NewIOParser(24, 80, strings.NewReader(""))
+
+	p.SetWinSize(&WinSize{Row: 30, Col: 100})
+	p.SetWinSize(&WinSize{Row: 40, Col: 120})
+	p.SetWinSize(&WinSize{Row: 0, Col: 10})
+
+	expected := &WinSize{Row: 40, Col: 120}
+	if ws := p.GetWinSize(); !reflect.DeepEqual(ws, expected) {
+		t.Errorf("Should be %#v, but got %#v", expected, ws)
+	}
+
+	select {
+	case ws := <-p.WinSizeChanged():
+		if !reflect.DeepEqual(ws, expected) {
+			t.Errorf("Should be %#v, but got %#v", expected, ws)
+		}
+	default:
+		t.Error("Should be notified of the window size change")
+	}
+
+	select {
+	case ws := <-p.WinSizeChanged():
+		t.Errorf("Should only keep the latest window size, but got %#v", ws)
+	default:
+	}
+}
@@ -23,6 +23,9 @@ const (
 	CommonKeyBind KeyBindMode = "common"
 	// EmacsKeyBind is a mode to use emacs-like keyboard shortcut
 	EmacsKeyBind KeyBindMode = "emacs"
+	// ViKeyBind is a mode to use vi-like keyboard shortcut, starting in
+	// insert mode
+	ViKeyBind KeyBindMode = "vi"
 )
 
 var commonKeyBindings = []KeyBind{
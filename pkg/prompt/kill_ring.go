@@ -0,0 +1,80 @@
+package prompt
+
+// maxKills limits the number of entries in the kill ring.
+const maxKills = 16
+
+// killCommand is the kind of a command using the kill ring.
+type killCommand int
+
+const (
+	killNone killCommand = iota
+	killForward
+	killBackward
+	killYank
+)
+
+// killRing holds the text cut by kill commands, the latest last, so that it
+// can be yanked back.
+type killRing struct {
+	entries []string
+	// yanked is the index of the entry yanked last, start and end its
+	// position in the buffer.
+	yanked     int
+	start, end int
+	// last is the previous command, consecutive kills are joined into one
+	// entry and Alt-Y only follows a yank.
+	last killCommand
+}
+
+// kill adds text cut by the command, to the latest entry if the previous
+// command killed text too.
+func (k *killRing) kill(text string, command killCommand) {
+	last := k.last
+	k.last = command
+	if text == "" {
+		return
+	}
+	if n := len(k.entries); n > 0 && (last == killForward || last == killBackward) {
+		if command == killBackward {
+			k.entries[n-1] = text + k.entries[n-1]
+		} else {
+			k.entries[n-1] += text
+		}
+		return
+	}
+	k.entries = append(k.entries, text)
+	if len(k.entries) > maxKills {
+		k.entries = k.entries[len(k.entries)-maxKills:]
+	}
+}
+
+// yank inserts the latest entry at the cursor.
+func (k *killRing) yank(buf *Buffer) {
+	if len(k.entries) == 0 {
+		k.last = killNone
+		return
+	}
+	k.yanked = len(k.entries) - 1
+	k.start = buf.cursorPosition
+	buf.InsertText(k.entries[k.yanked], false, true)
+	k.end = buf.cursorPosition
+	k.last = killYank
+}
+
+// yankPop replaces the text yanked last by the entry before it, cycling
+// through the ring.
+func (k *killRing) yankPop(buf *Buffer) {
+	if k.last != killYank {
+		k.last = killNone
+		return
+	}
+	k.yanked = (k.yanked + len(k.entries) - 1) % len(k.entries)
+	text := k.entries[k.yanked]
+	r := []rune(buf.Text())
+	buf.saveUndo(false)
+	buf.setDocument(&Document{
+		Text:           string(r[:k.start]) + text + string(r[k.end:]),
+		cursorPosition: k.start + len([]rune(text)),
+	})
+	k.end = buf.cursorPosition
+}
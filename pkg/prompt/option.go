@@ -206,10 +206,24 @@ func OptionHistory(x []string) Option {
 	}
 }
 
+// OptionHistoryStore to set a store the entered texts are appended to, the
+// texts it holds are not loaded, use OptionHistory for them.
+func OptionHistoryStore(x HistoryStore) Option {
+	return func(p *Prompt) error {
+		p.history.store = x
+		return nil
+	}
+}
+
 // OptionSwitchKeyBindMode set a key bind mode.
 func OptionSwitchKeyBindMode(m KeyBindMode) Option {
 	return func(p *Prompt) error {
 		p.keyBindMode = m
+		p.vi = nil
+		if m == ViKeyBind {
+			p.vi = &viState{}
+		}
+		p.renderer.vi = p.vi
 		return nil
 	}
 }
@@ -250,6 +264,15 @@ func OptionShowCompletionAtStart() Option {
 	}
 }
 
+// OptionConfirmMultilinePaste to ask before the lines of a multi-line paste
+// are run.
+func OptionConfirmMultilinePaste() Option {
+	return func(p *Prompt) error {
+		p.pasteConfirm = true
+		return nil
+	}
+}
+
 // OptionBreakLineCallback to run a callback at every break line
 func OptionBreakLineCallback(fn func(*Document)) Option {
 	return func(p *Prompt) error {
@@ -158,4 +158,11 @@ type ConsoleWriter interface {
 
 	// SetColor sets text and background colors. and specify whether text is bold.
 	SetColor(fg, bg Color, bold bool)
+
+	/* Paste */
+
+	// EnableBracketedPaste makes the terminal enclose pasted text in markers.
+	EnableBracketedPaste()
+	// DisableBracketedPaste makes the terminal send pasted text as typed.
+	DisableBracketedPaste()
 }
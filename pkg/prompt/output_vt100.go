@@ -206,6 +206,18 @@ func (w *VT100Writer) ClearTitle() {
 	w.WriteRaw([]byte{0x1b, ']', '2', ';', 0x07})
 }
 
+/* Paste */
+
+// EnableBracketedPaste makes the terminal enclose pasted text in markers.
+func (w *VT100Writer) EnableBracketedPaste() {
+	w.WriteRaw([]byte{0x1b, '[', '?', '2', '0', '0', '4', 'h'})
+}
+
+// DisableBracketedPaste makes the terminal send pasted text as typed.
+func (w *VT100Writer) DisableBracketedPaste() {
+	w.WriteRaw([]byte{0x1b, '[', '?', '2', '0', '0', '4', 'l'})
+}
+
 /* Font */
 
 // SetColor sets text and background colors. and specify whether text is bold.
@@ -0,0 +1,215 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+var (
+	// pasteStart and pasteEnd enclose pasted text in bracketed paste mode.
+	pasteStart = []byte{0x1b, '[', '2', '0', '0', '~'}
+	pasteEnd   = []byte{0x1b, '[', '2', '0', '1', '~'}
+)
+
+// maxPasteBytes limits the size of a paste, the rest of a longer paste is
+// dropped.
+const maxPasteBytes = 1 << 20
+
+// pasteReader returns a bracketed paste as one chunk including its markers,
+// even if the terminal sent it in several chunks. Input before and after the
+// paste is returned in chunks of its own.
+type pasteReader struct {
+	read    func(ctx context.Context) ([]byte, error)
+	pending []byte
+	// discard is true while the rest of a paste longer than maxPasteBytes is
+	// dropped, tail holds the end of the dropped input in case the end
+	// marker is split.
+	discard bool
+	tail    []byte
+}
+
+// Read returns the next chunk of input, a paste read partly when ctx is
+// done is completed by the next read.
+func (r *pasteReader) Read(ctx context.Context) ([]byte, error) {
+	b := r.pending
+	r.pending = nil
+	if len(b) == 0 {
+		var err error
+		if b, err = r.read(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.discard {
+		b = append(r.tail, b...)
+		i := bytes.Index(b, pasteEnd)
+		if i < 0 {
+			r.tail = lastBytes(b, len(pasteEnd)-1)
+			return nil, nil
+		}
+		r.discard, r.tail = false, nil
+		b = b[i+len(pasteEnd):]
+	}
+
+	i := bytes.Index(b, pasteStart)
+	switch {
+	case i < 0:
+		return b, nil
+	case i > 0:
+		r.pending = b[i:]
+		return b[:i], nil
+	}
+
+	for {
+		if j := bytes.Index(b[len(pasteStart):], pasteEnd); j >= 0 {
+			end := len(pasteStart) + j + len(pasteEnd)
+			r.pending = b[end:]
+			return b[:end], nil
+		}
+		if len(b) > maxPasteBytes {
+			_log.Infow("paste is too long, dropping the rest", "limit", maxPasteBytes)
+			r.discard = true
+			r.tail = append([]byte(nil), lastBytes(b, len(pasteEnd)-1)...)
+			return append(b[:maxPasteBytes:maxPasteBytes], pasteEnd...), nil
+		}
+		more, err := r.read(ctx)
+		if err != nil {
+			r.pending = b
+			return nil, err
+		}
+		b = append(b, more...)
+	}
+}
+
+func lastBytes(b []byte, n int) []byte {
+	if len(b) < n {
+		return b
+	}
+	return b[len(b)-n:]
+}
+
+// pasteLines splits pasted text into lines, other control characters such as
+// tabs are replaced by spaces.
+func pasteLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = strings.Map(func(r rune) rune {
+			if r < ' ' || r == 0x7f {
+				return ' '
+			}
+			return r
+		}, l)
+	}
+	return lines
+}
+
+// pasteConfirm is the state of asking whether the lines of a multi-line
+// paste are run.
+type pasteConfirm struct {
+	lines []string
+	// rest is the text after the last line break, it is kept in the buffer.
+	rest *Buffer
+}
+
+// prefix returns the prefix rendered while asking.
+func (c *pasteConfirm) prefix() string {
+	return fmt.Sprintf("run %d pasted lines? [y/N] ", len(c.lines))
+}
+
+// feedPaste inserts pasted text literally into the buffer. Every complete
+// line of a multi-line paste is run as if it was entered, once confirmed if
+// pasteConfirm is set.
+func (p *Prompt) feedPaste(text string) *Exec {
+	if p.search != nil {
+		p.stopSearch()
+	}
+	if p.paste != nil {
+		p.cancelPaste()
+	}
+	p.completion.Reset()
+
+	lines := pasteLines(text)
+	last := lines[len(lines)-1]
+	if len(lines) == 1 {
+		p.buf.InsertText(last, false, true)
+		return nil
+	}
+
+	p.buf.InsertText(lines[0], false, true)
+	lines[0] = p.buf.Text()
+	run := make([]string, 0, len(lines)-1)
+	for _, l := range lines[:len(lines)-1] {
+		if strings.TrimSpace(l) != "" {
+			run = append(run, l)
+		}
+	}
+	rest := newTextBuffer(last)
+	if len(run) == 0 {
+		p.buf = rest
+		return nil
+	}
+
+	if !p.pasteConfirm {
+		return p.runPasted(run, rest)
+	}
+	p.paste = &pasteConfirm{lines: run, rest: rest}
+	p.renderer.paste = p.paste
+	p.renderer.renderLines(false, run...)
+	p.buf = NewBuffer()
+	return nil
+}
+
+// feedPasteConfirm handles the answer whether the pasted lines are run.
+func (p *Prompt) feedPasteConfirm(key Key, b []byte) *Exec {
+	c := p.paste
+	p.paste, p.renderer.paste = nil, nil
+	if key == NotDefined && (string(b) == "y" || string(b) == "Y") {
+		return p.runPasted(c.lines, c.rest)
+	}
+	p.buf = c.rest
+	return nil
+}
+
+// cancelPaste stops asking and drops the pasted lines.
+func (p *Prompt) cancelPaste() {
+	p.buf = p.paste.rest
+	p.paste, p.renderer.paste = nil, nil
+}
+
+// runPasted returns the first line to execute, the others are queued and
+// run after it.
+func (p *Prompt) runPasted(lines []string, rest *Buffer) *Exec {
+	p.renderer.renderLines(true, lines[0])
+	for _, l := range lines {
+		p.history.Add(l)
+	}
+	p.buf = rest
+	p.queue = lines[1:]
+	return &Exec{input: lines[0]}
+}
+
+// execute runs the input and the pasted lines queued after it, it returns
+// true if the prompt must exit.
+func (p *Prompt) execute(ctx context.Context, input string) bool {
+	p.renderer.bracketedPaste(false)
+	defer p.renderer.bracketedPaste(true)
+	for {
+		_log.Debugw("executing", "input", input)
+		p.executor(ctx, input)
+
+		if p.exitChecker != nil && p.exitChecker(input, true) {
+			p.queue = nil
+			return true
+		}
+		if len(p.queue) == 0 || ctx.Err() != nil {
+			p.queue = nil
+			return false
+		}
+		input, p.queue = p.queue[0], p.queue[1:]
+		p.renderer.renderLines(true, input)
+	}
+}
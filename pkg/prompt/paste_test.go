@@ -0,0 +1,175 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func pasted(s string) []byte {
+	return append(append(append([]byte{}, pasteStart...), s...), pasteEnd...)
+}
+
+func readChunks(t *testing.T, chunks ...[]byte) [][]byte {
+	t.Helper()
+	r := &pasteReader{read: func(context.Context) ([]byte, error) {
+		if len(chunks) == 0 {
+			return nil, io.EOF
+		}
+		b := chunks[0]
+		chunks = chunks[1:]
+		return b, nil
+	}}
+
+	var got [][]byte
+	for {
+		b, err := r.Read(context.Background())
+		if errors.Is(err, io.EOF) {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 0 {
+			got = append(got, b)
+		}
+	}
+}
+
+func TestPasteReader(t *testing.T) {
+	paste := pasted("get pods\n")
+	tests := []struct {
+		name   string
+		chunks [][]byte
+		want   [][]byte
+	}{
+		{
+			name:   "keys",
+			chunks: [][]byte{[]byte("a"), {0x1b, '[', 'A'}},
+			want:   [][]byte{[]byte("a"), {0x1b, '[', 'A'}},
+		},
+		{
+			name:   "paste",
+			chunks: [][]byte{paste},
+			want:   [][]byte{paste},
+		},
+		{
+			name:   "split paste",
+			chunks: [][]byte{paste[:8], paste[8:12], paste[12:]},
+			want:   [][]byte{paste},
+		},
+		{
+			name:   "split end marker",
+			chunks: [][]byte{paste[:len(paste)-3], paste[len(paste)-3:]},
+			want:   [][]byte{paste},
+		},
+		{
+			name:   "input around paste",
+			chunks: [][]byte{append([]byte("ab"), paste[:10]...), append(paste[10:], 'c')},
+			want:   [][]byte{[]byte("ab"), paste, []byte("c")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readChunks(t, tt.chunks...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasteReaderLimit(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), maxPasteBytes)
+	got := readChunks(t, append(append([]byte{}, pasteStart...), long...), []byte("yyy"), pasteEnd[:2], append(pasteEnd[2:], 'a'))
+	if len(got) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(got))
+	}
+	if !bytes.HasPrefix(got[0], pasteStart) || !bytes.HasSuffix(got[0], pasteEnd) {
+		t.Errorf("paste is not enclosed in markers")
+	}
+	if got, want := len(got[0]), maxPasteBytes+len(pasteEnd); got != want {
+		t.Errorf("paste has %d bytes, want %d", got, want)
+	}
+	if string(got[1]) != "a" {
+		t.Errorf("input after paste = %q, want %q", got[1], "a")
+	}
+}
+
+func newPastePrompt(opts ...Option) (*Prompt, *[]string) {
+	var executed []string
+	opts = append([]Option{OptionWriter(NewIOWriter(&bytes.Buffer{}))}, opts...)
+	p := New(
+		func(ctx context.Context, s string) { executed = append(executed, s) },
+		func(Document) []Suggest { return []Suggest{{Text: "pods"}} },
+		opts...,
+	)
+	p.renderer.UpdateWinSize(&WinSize{Row: 24, Col: 80})
+	return p, &executed
+}
+
+func TestFeedPaste(t *testing.T) {
+	t.Run("single line", func(t *testing.T) {
+		p, _ := newPastePrompt()
+		feedString(p, "get ")
+		if _, e := p.feed(pasted("po\tds -o\tyaml")); e != nil {
+			t.Fatalf("paste executed %q", e.input)
+		}
+		if got, want := p.buf.Text(), "get po ds -o yaml"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("multiple lines", func(t *testing.T) {
+		p, executed := newPastePrompt()
+		feedString(p, "get ")
+		_, e := p.feed(pasted("pods\r\n\nget nodes\nlogs"))
+		if e == nil {
+			t.Fatal("paste did not execute")
+		}
+		p.execute(context.Background(), e.input)
+		if want := []string{"get pods", "get nodes"}; !reflect.DeepEqual(*executed, want) {
+			t.Errorf("executed %q, want %q", *executed, want)
+		}
+		if got, want := p.buf.Text(), "logs"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+		if got, want := p.history.histories, []string{"get pods", "get nodes"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("history = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("confirmed", func(t *testing.T) {
+		p, executed := newPastePrompt(OptionConfirmMultilinePaste())
+		if _, e := p.feed(pasted("get pods\nget nodes\n")); e != nil {
+			t.Fatalf("paste executed %q before confirmation", e.input)
+		}
+		if got, want := p.renderer.getCurrentPrefix(), "run 2 pasted lines? [y/N] "; got != want {
+			t.Errorf("prefix = %q, want %q", got, want)
+		}
+		_, e := p.feed([]byte("y"))
+		if e == nil {
+			t.Fatal("paste did not execute")
+		}
+		p.execute(context.Background(), e.input)
+		if want := []string{"get pods", "get nodes"}; !reflect.DeepEqual(*executed, want) {
+			t.Errorf("executed %q, want %q", *executed, want)
+		}
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		p, _ := newPastePrompt(OptionConfirmMultilinePaste())
+		p.feed(pasted("get pods\nget nodes\nlogs"))
+		if _, e := p.feed([]byte{0xd}); e != nil {
+			t.Fatalf("declined paste executed %q", e.input)
+		}
+		if got, want := p.buf.Text(), "logs"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+		if got, want := p.renderer.getCurrentPrefix(), "> "; got != want {
+			t.Errorf("prefix = %q, want %q", got, want)
+		}
+	})
+}
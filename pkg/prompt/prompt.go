@@ -3,7 +3,6 @@ package prompt
 import (
 	"bytes"
 	"context"
-	"time"
 
 	logv2 "github.com/paralus/paralus/pkg/log"
 )
@@ -32,13 +31,32 @@ type Prompt struct {
 	renderer          *Render
 	executor          Executor
 	history           *History
+	search            *historySearch
 	completion        *CompletionManager
 	keyBindings       []KeyBind
 	ASCIICodeBindings []ASCIICodeBind
 	keyBindMode       KeyBindMode
+	vi                *viState
 	completionOnDown  bool
 	exitChecker       ExitChecker
 	skipTearDown      bool
+	// kills is the kill ring of the emacs key bindings, ctrlX is true after
+	// the Ctrl-X prefix.
+	kills killRing
+	ctrlX bool
+	// pasteConfirm asks before the lines of a multi-line paste are run,
+	// paste is set while asking.
+	pasteConfirm bool
+	paste        *pasteConfirm
+	// queue holds the pasted lines to run after the current input.
+	queue []string
+	// decoder and unread are only used by the reader of the input, unread
+	// holds events read but not fed when reading stopped.
+	decoder Decoder
+	unread  []KeyEvent
+	// inflight is a read of a parser which can not be cancelled, which
+	// outlasted the reader.
+	inflight backgroundRead
 }
 
 // Exec is the struct contains user input context.
@@ -49,71 +67,148 @@ type Exec struct {
 // Run starts prompt.
 func (p *Prompt) Run(ctx context.Context) {
 	p.skipTearDown = false
-	// defer debug.Teardown()
-	// debug.Log("start prompt")
 	p.setUp()
 	defer p.tearDown()
 
 	if p.completion.showAtStart {
 		p.completion.Update(*p.buf.Document())
 	}
+	p.loop(ctx)
+}
+
+// RunPreset starts preset command.
+func (p *Prompt) RunPreset(ctx context.Context, command string) {
+	p.skipTearDown = false
+	p.setUp()
+	defer p.tearDown()
 
+	if p.completion.showAtStart {
+		p.completion.Update(*p.buf.Document())
+	}
+
+	p.in.TearDown()
+	p.execute(ctx, command)
+	p.in.Setup()
+	p.completion.Update(*p.buf.Document())
+	p.loop(ctx)
+}
+
+// loop renders the prompt and feeds the key events until the prompt exits or
+// ctx is done. The input is only read while the prompt waits for keys, the
+// executor owns it while it runs.
+func (p *Prompt) loop(ctx context.Context) {
 	p.renderer.Render(p.buf, p.completion)
+	winSizeCh := p.winSizeChanged()
 
-	bufCh := make(chan []byte, 1)
-	stopReadBufCh := make(chan struct{})
-	go p.readBuffer(bufCh, stopReadBufCh)
+	var r *reader
+	defer func() {
+		if r != nil {
+			r.stop()
+		}
+	}()
 
-promptLoop:
+	// once reading failed the prompt waits for ctx
+	failed := false
 	for {
+		var events <-chan []KeyEvent
+		if !failed {
+			if r == nil {
+				r = p.startReading(ctx)
+			}
+			events = r.events
+		}
+
 		select {
 		case <-ctx.Done():
-			stopReadBufCh <- struct{}{}
-			break promptLoop
-
-		case b := <-bufCh:
-			if shouldExit, e := p.feed(b); shouldExit {
-				p.renderer.BreakLine(p.buf)
-				//p.in.Close()
-				stopReadBufCh <- struct{}{}
-				return
-			} else if e != nil && e.input != "" {
-				_log.Debugw("executing", "input", e.input)
-
-				// Stop goroutine to run readBuffer function
-				stopReadBufCh <- struct{}{}
-				p.in.TearDown()
+			return
+
+		case ws := <-winSizeCh:
+			p.renderer.UpdateWinSize(ws)
+			p.completion.Update(*p.buf.Document())
+			p.renderer.Render(p.buf, p.completion)
+			_log.Debugw("rendering prompt after resize", "rows", ws.Row, "cols", ws.Col)
+
+		case evs, ok := <-events:
+			if !ok {
+				_log.Infow("unable to read input", "error", r.err)
+				r.stop()
+				r, failed = nil, true
+				continue
+			}
+			for _, ev := range evs {
+				shouldExit, e := p.feedKey(ev)
+				if shouldExit {
+					p.renderer.BreakLine(p.buf)
+					return
+				}
+				if e == nil || e.input == "" {
+					continue
+				}
 
-				_log.Debugw("executing", "input", e.input)
-				p.executor(ctx, e.input)
+				// the executor owns the input until it returns, the
+				// events left are fed afterwards.
+				if r != nil {
+					r.stop()
+					r = nil
+				}
+				p.in.TearDown()
+				exit := p.execute(ctx, e.input)
 
 				p.completion.Update(*p.buf.Document())
 				p.renderer.Render(p.buf, p.completion)
 				_log.Debugw("rendering prompt after executing")
 
-				if p.exitChecker != nil && p.exitChecker(e.input, true) {
+				if exit {
 					p.skipTearDown = true
 					return
 				}
-
-				_log.Debugw("starting read buffer again")
 				p.in.Setup()
-				go p.readBuffer(bufCh, stopReadBufCh)
-			} else {
-				p.completion.Update(*p.buf.Document())
-				p.renderer.Render(p.buf, p.completion)
-				_log.Debugw("rendering prompt without executing")
 			}
+			if r != nil {
+				r.more()
+			}
+			p.completion.Update(*p.buf.Document())
+			p.renderer.Render(p.buf, p.completion)
 		}
 	}
 }
 
+// feed decodes the input and feeds its events until one exits or executes
+// input.
 func (p *Prompt) feed(b []byte) (shouldExit bool, exec *Exec) {
-	key := GetKey(b)
+	var d Decoder
+	for _, ev := range append(d.Decode(b), d.Flush()...) {
+		if shouldExit, exec = p.feedKey(ev); shouldExit || exec != nil {
+			return
+		}
+	}
+	return
+}
+
+func (p *Prompt) feedKey(ev KeyEvent) (shouldExit bool, exec *Exec) {
+	key, b := ev.Key, ev.Data
+	if key == BracketedPaste {
+		exec = p.feedPaste(string(b))
+		shouldExit = exec == nil && p.exitChecker != nil && p.exitChecker(p.buf.Text(), false)
+		return
+	}
+	if p.paste != nil {
+		exec = p.feedPasteConfirm(key, b)
+		return
+	}
+	if p.search != nil && p.feedSearch(key, b) {
+		return
+	}
 	p.buf.lastKeyStroke = key
 	// completion
 	completing := p.completion.Completing()
 	p.handleCompletionKeyBinding(key, completing)
+	if p.vi != nil && p.feedVi(ev) {
+		return
+	}
+	if p.keyBindMode == EmacsKeyBind && p.feedEmacs(ev) {
+		return
+	}
 
 	switch key {
 	case Enter, ControlJ, ControlM:
@@ -121,12 +216,14 @@ func (p *Prompt) feed(b []byte) (shouldExit bool, exec *Exec) {
 		_log.Debugw("enter key pressed", "buffer", p.buf.Text())
 		exec = &Exec{input: p.buf.Text()}
 		p.buf = NewBuffer()
+		p.vi.reset()
 		if exec.input != "" {
 			p.history.Add(exec.input)
 		}
 	case ControlC:
 		p.renderer.BreakLine(p.buf)
 		p.buf = NewBuffer()
+		p.vi.reset()
 		p.history.Clear()
 	case Up, ControlP:
 		if !completing { // Don't use p.completion.Completing() because it takes double operation when switch to selected=-1.
@@ -141,6 +238,9 @@ func (p *Prompt) feed(b []byte) (shouldExit bool, exec *Exec) {
 			}
 			return
 		}
+	case ControlR:
+		p.startSearch()
+		return
 	case ControlD:
 		if p.buf.Text() == "" {
 			shouldExit = true
@@ -150,7 +250,10 @@ func (p *Prompt) feed(b []byte) (shouldExit bool, exec *Exec) {
 		if p.handleASCIICodeBinding(b) {
 			return
 		}
-		p.buf.InsertText(string(b), false, true)
+		// unknown sequences and keys pressed with Alt are no text
+		if ev.Mod == 0 && b[0] != 0x1b {
+			p.buf.InsertText(string(b), false, true)
+		}
 	}
 
 	shouldExit = p.handleKeyBinding(key)
@@ -201,6 +304,15 @@ func (p *Prompt) handleKeyBinding(key Key) bool {
 		}
 	}
 
+	if p.keyBindMode == ViKeyBind && !p.vi.normal {
+		for i := range viInsertKeyBindings {
+			kb := viInsertKeyBindings[i]
+			if kb.Key == key {
+				kb.Fn(p.buf)
+			}
+		}
+	}
+
 	// Custom key bindings
 	for i := range p.keyBindings {
 		kb := p.keyBindings[i]
@@ -216,6 +328,14 @@ func (p *Prompt) handleKeyBinding(key Key) bool {
 
 func (p *Prompt) handleASCIICodeBinding(b []byte) bool {
 	checked := false
+	if p.keyBindMode == EmacsKeyBind {
+		for _, kb := range emacsASCIICodeBindings {
+			if bytes.Equal(kb.ASCIICode, b) {
+				kb.Fn(p.buf)
+				checked = true
+			}
+		}
+	}
 	for _, kb := range p.ASCIICodeBindings {
 		if bytes.Equal(kb.ASCIICode, b) {
 			kb.Fn(p.buf)
@@ -225,28 +345,13 @@ func (p *Prompt) handleASCIICodeBinding(b []byte) bool {
 	return checked
 }
 
-func (p *Prompt) readBuffer(bufCh chan []byte, stopCh chan struct{}) {
-	_log.Debugw("start reading buffer")
-readLoop:
-	for {
-		select {
-		case <-stopCh:
-			_log.Debugw("stop reading buffer")
-			return
-		default:
-			b, err := p.in.Read()
-			if err != nil {
-				_log.Infow("unable to read buffer", "error", err)
-				break readLoop
-			}
-
-			if len(b) > 0 && !(len(b) == 1 && b[0] == 0) {
-				bufCh <- b
-			}
-
-		}
-		time.Sleep(10 * time.Millisecond)
+// winSizeChanged returns the channel on which the parser reports window size
+// changes, nil when the size can only be read at setup.
+func (p *Prompt) winSizeChanged() <-chan *WinSize {
+	if rp, ok := p.in.(ResizableParser); ok {
+		return rp.WinSizeChanged()
 	}
+	return nil
 }
 
 func (p *Prompt) setUp() {
@@ -261,68 +366,3 @@ func (p *Prompt) tearDown() {
 	// }
 	p.renderer.TearDown()
 }
-
-// RunPreset starts preset command.
-func (p *Prompt) RunPreset(ctx context.Context, command string) {
-	p.skipTearDown = false
-	// defer debug.Teardown()
-	// debug.Log("start prompt")
-	p.setUp()
-	defer p.tearDown()
-
-	if p.completion.showAtStart {
-		p.completion.Update(*p.buf.Document())
-	}
-
-	p.executor(ctx, command)
-	p.completion.Update(*p.buf.Document())
-
-	p.renderer.Render(p.buf, p.completion)
-
-	bufCh := make(chan []byte, 1)
-	stopReadBufCh := make(chan struct{})
-	go p.readBuffer(bufCh, stopReadBufCh)
-
-presetLoop:
-	for {
-		select {
-		case <-ctx.Done():
-			stopReadBufCh <- struct{}{}
-			break presetLoop
-
-		case b := <-bufCh:
-			if shouldExit, e := p.feed(b); shouldExit {
-				p.renderer.BreakLine(p.buf)
-				//p.in.Close()
-				stopReadBufCh <- struct{}{}
-				return
-			} else if e != nil && e.input != "" {
-				_log.Debugw("executing", "input", e.input)
-
-				// Stop goroutine to run readBuffer function
-				stopReadBufCh <- struct{}{}
-				p.in.TearDown()
-
-				_log.Debugw("executing", "input", e.input)
-				p.executor(ctx, e.input)
-
-				p.completion.Update(*p.buf.Document())
-				p.renderer.Render(p.buf, p.completion)
-				_log.Debugw("rendering prompt after executing")
-
-				if p.exitChecker != nil && p.exitChecker(e.input, true) {
-					p.skipTearDown = true
-					return
-				}
-
-				_log.Debugw("starting read buffer again")
-				p.in.Setup()
-				go p.readBuffer(bufCh, stopReadBufCh)
-			} else {
-				p.completion.Update(*p.buf.Document())
-				p.renderer.Render(p.buf, p.completion)
-				_log.Debugw("rendering prompt without executing")
-			}
-		}
-	}
-}
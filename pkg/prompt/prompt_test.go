@@ -0,0 +1,196 @@
+package prompt
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// fakeTerminal is the input typed by a test, it can be read with
+// ReadContext like a terminal attached over a websocket.
+type fakeTerminal struct {
+	in chan []byte
+}
+
+func newFakeTerminal() *fakeTerminal {
+	return &fakeTerminal{in: make(chan []byte)}
+}
+
+func (f *fakeTerminal) Read(p []byte) (int, error) {
+	return f.ReadContext(context.Background(), p)
+}
+
+func (f *fakeTerminal) ReadContext(ctx context.Context, p []byte) (int, error) {
+	select {
+	case b, ok := <-f.in:
+		if !ok {
+			return 0, io.EOF
+		}
+		return copy(p, b), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (f *fakeTerminal) typeString(t *testing.T, s string) {
+	t.Helper()
+	select {
+	case f.in <- []byte(s):
+	case <-time.After(time.Second):
+		t.Fatalf("%q was not read", s)
+	}
+}
+
+func newRunPrompt(r io.Reader, executor Executor) *Prompt {
+	return New(
+		executor,
+		func(Document) []Suggest { return nil },
+		OptionParser(NewIOParser(24, 80, r)),
+		OptionWriter(NewIOWriter(io.Discard)),
+	)
+}
+
+// run runs the prompt until the returned function cancels it, which fails
+// if the prompt does not return.
+func run(t *testing.T, fn func(ctx context.Context)) (done chan struct{}, stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+	return done, func() {
+		t.Helper()
+		cancel()
+		waitDone(t, done)
+	}
+}
+
+func waitDone(t *testing.T, done chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("prompt did not return")
+	}
+}
+
+func receive(t *testing.T, ch chan string) string {
+	t.Helper()
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("nothing was executed")
+		return ""
+	}
+}
+
+func TestRun(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	term := newFakeTerminal()
+	executed := make(chan string, 1)
+	p := newRunPrompt(term, func(ctx context.Context, s string) { executed <- s })
+	_, stop := run(t, p.Run)
+	defer stop()
+
+	term.typeString(t, "get po")
+	term.typeString(t, "ds\r")
+	if got, want := receive(t, executed), "get pods"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+
+	// keys coalesced into one chunk
+	term.typeString(t, "get\x1b[Dx\r")
+	if got, want := receive(t, executed), "gext"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+}
+
+func TestRunPreset(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	term := newFakeTerminal()
+	executed := make(chan string, 1)
+	p := newRunPrompt(term, func(ctx context.Context, s string) { executed <- s })
+	_, stop := run(t, func(ctx context.Context) { p.RunPreset(ctx, "version") })
+	defer stop()
+
+	if got, want := receive(t, executed), "version"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+	term.typeString(t, "get nodes\r")
+	if got, want := receive(t, executed), "get nodes"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+}
+
+func TestRunExit(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	term := newFakeTerminal()
+	p := newRunPrompt(term, func(ctx context.Context, s string) {})
+	done, stop := run(t, p.Run)
+	defer stop()
+
+	term.typeString(t, "\x04")
+	waitDone(t, done)
+}
+
+func TestRunExecutorOwnsInput(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	term := newFakeTerminal()
+	answers := make(chan string, 1)
+	executed := make(chan string, 1)
+	p := newRunPrompt(term, func(ctx context.Context, s string) {
+		if s == "delete pod web" {
+			b := make([]byte, 16)
+			n, err := term.ReadContext(ctx, b)
+			if err != nil {
+				t.Error(err)
+			}
+			answers <- string(b[:n])
+		}
+		executed <- s
+	})
+	_, stop := run(t, p.Run)
+	defer stop()
+
+	term.typeString(t, "delete pod web\r")
+	term.typeString(t, "y")
+	if got, want := receive(t, answers), "y"; got != want {
+		t.Errorf("executor read %q, want %q", got, want)
+	}
+	receive(t, executed)
+
+	term.typeString(t, "get pods\r")
+	if got, want := receive(t, executed), "get pods"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+}
+
+func TestRunReaderWithoutContext(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	r, w := io.Pipe()
+	executed := make(chan string, 1)
+	p := newRunPrompt(r, func(ctx context.Context, s string) { executed <- s })
+	_, stop := run(t, p.Run)
+
+	if _, err := w.Write([]byte("get pods\r")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := receive(t, executed), "get pods"; got != want {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+
+	// the read pending when the prompt returns ends with the input
+	stop()
+	w.Close()
+}
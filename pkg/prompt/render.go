@@ -16,6 +16,15 @@ type Render struct {
 	col                uint16
 
 	previousCursor int
+	// search is set while searching the history, its prefix replaces the
+	// prefix.
+	search *historySearch
+	// paste is set while asking whether to run pasted lines, its prefix
+	// replaces the prefix.
+	paste *pasteConfirm
+	// vi is set with the vi key bindings, its mode indicator is rendered
+	// before the prefix.
+	vi *viState
 
 	// colors,
 	prefixTextColor              Color
@@ -40,17 +49,37 @@ type Render struct {
 func (r *Render) Setup() {
 	if r.title != "" {
 		r.out.SetTitle(r.title)
-		debug.AssertNoError(r.out.Flush())
 	}
+	r.out.EnableBracketedPaste()
+	debug.AssertNoError(r.out.Flush())
+}
+
+// bracketedPaste enables or disables bracketed paste mode, it is disabled
+// while commands run so that they do not read the paste markers.
+func (r *Render) bracketedPaste(enable bool) {
+	if enable {
+		r.out.EnableBracketedPaste()
+	} else {
+		r.out.DisableBracketedPaste()
+	}
+	debug.AssertNoError(r.out.Flush())
 }
 
 // getCurrentPrefix to get current prefix.
 // If live-prefix is enabled, return live-prefix.
+// While searching the history, return the search prefix.
+// With the vi key bindings, the prefix starts with the mode indicator.
 func (r *Render) getCurrentPrefix() string {
+	if r.search != nil {
+		return r.search.prefix()
+	}
+	if r.paste != nil {
+		return r.paste.prefix()
+	}
 	if prefix, ok := r.livePrefixCallback(); ok {
-		return prefix
+		return r.vi.indicator() + prefix
 	}
-	return r.prefix
+	return r.vi.indicator() + r.prefix
 }
 
 func (r *Render) renderPrefix() {
@@ -62,6 +91,7 @@ func (r *Render) renderPrefix() {
 // TearDown to clear title and erasing.
 func (r *Render) TearDown() {
 	r.out.ClearTitle()
+	r.out.DisableBracketedPaste()
 	r.out.EraseDown()
 	debug.AssertNoError(r.out.Flush())
 }
@@ -201,6 +231,11 @@ func (r *Render) Render(buffer *Buffer, completion *CompletionManager) {
 
 	cursor = r.backward(cursor, runewidth.StringWidth(line)-buffer.DisplayCursorPosition())
 
+	if r.search != nil {
+		r.previousCursor = cursor
+		return
+	}
+
 	r.renderCompletion(buffer, completion)
 	if suggest, ok := completion.GetSelectedSuggestion(); ok {
 		cursor = r.backward(cursor, runewidth.StringWidth(buffer.Document().GetWordBeforeCursorUntilSeparator(completion.wordSeparator)))
@@ -237,6 +272,30 @@ func (r *Render) BreakLine(buffer *Buffer) {
 	r.previousCursor = 0
 }
 
+// renderLines replaces the input with the lines, entered lines are rendered
+// after the prefix like by BreakLine, others are indented.
+func (r *Render) renderLines(entered bool, lines ...string) {
+	r.move(r.previousCursor, 0)
+	r.out.EraseDown()
+	for _, l := range lines {
+		if entered {
+			r.renderPrefix()
+		} else {
+			r.out.WriteStr("  ")
+		}
+		r.out.SetColor(r.inputTextColor, r.inputBGColor, false)
+		r.out.WriteStr(l + "\r\n")
+		r.out.SetColor(DefaultColor, DefaultColor, false)
+	}
+	debug.AssertNoError(r.out.Flush())
+	if entered && r.breakLineCallback != nil {
+		for _, l := range lines {
+			r.breakLineCallback(&Document{Text: l, cursorPosition: len([]rune(l))})
+		}
+	}
+	r.previousCursor = 0
+}
+
 // clear erases the screen from a beginning of input
 // even if there is line break which means input length exceeds a window's width.
 func (r *Render) clear(cursor int) {
@@ -0,0 +1,121 @@
+package prompt
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// historySearch is the state of a reverse incremental search in the history,
+// started by Ctrl-R.
+type historySearch struct {
+	query string
+	// index is the index of the matching entry in the history, -1 if no
+	// entry matched yet.
+	index int
+	// failed is true if the query does not match an older entry.
+	failed bool
+	// original is the buffer before searching, restored on cancel.
+	original *Buffer
+}
+
+// prefix returns the prefix rendered while searching.
+func (s *historySearch) prefix() string {
+	if s.failed {
+		return "(failed reverse-i-search)`" + s.query + "': "
+	}
+	return "(reverse-i-search)`" + s.query + "': "
+}
+
+// Search returns the index of the latest entry before index from which
+// contains query, false if no entry matches.
+func (h *History) Search(query string, from int) (int, bool) {
+	if from > len(h.histories) {
+		from = len(h.histories)
+	}
+	for i := from - 1; i >= 0; i-- {
+		if strings.Contains(h.histories[i], query) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// startSearch starts a reverse incremental search in the history.
+func (p *Prompt) startSearch() {
+	p.search = &historySearch{
+		index:    -1,
+		original: p.buf,
+	}
+	p.renderer.search = p.search
+	p.completion.Reset()
+}
+
+// stopSearch stops searching, keeping the buffer of the match.
+func (p *Prompt) stopSearch() {
+	p.search = nil
+	p.renderer.search = nil
+}
+
+// cancelSearch stops searching and restores the buffer before searching.
+func (p *Prompt) cancelSearch() {
+	p.buf = p.search.original
+	p.stopSearch()
+}
+
+// updateSearch searches the query in the entries before index from and
+// shows the match in the buffer, the last match is kept if nothing matches.
+func (p *Prompt) updateSearch(from int) {
+	s := p.search
+	if s.query == "" {
+		s.index, s.failed = -1, false
+		p.buf = s.original
+		return
+	}
+
+	i, ok := p.history.Search(s.query, from)
+	s.failed = !ok
+	if !ok {
+		return
+	}
+	s.index = i
+	text := p.history.histories[i]
+	p.buf = newTextBuffer(text)
+	p.buf.cursorPosition = utf8.RuneCountInString(text[:strings.Index(text, s.query)])
+}
+
+// feedSearch handles a key while searching. It returns true if the key was
+// handled, otherwise searching stops and the key is handled as usual.
+func (p *Prompt) feedSearch(key Key, b []byte) bool {
+	s := p.search
+	switch key {
+	case ControlR:
+		from := s.index
+		if from < 0 {
+			from = len(p.history.histories)
+		}
+		p.updateSearch(from)
+	case Escape, ControlG:
+		p.cancelSearch()
+	case Backspace, ControlH:
+		if s.query != "" {
+			r := []rune(s.query)
+			s.query = string(r[:len(r)-1])
+			p.updateSearch(len(p.history.histories))
+		}
+	case NotDefined:
+		if !utf8.Valid(b) || b[0] < ' ' {
+			return true
+		}
+		s.query += string(b)
+		// the current match is kept while it contains the longer query
+		from := len(p.history.histories)
+		if s.index >= 0 {
+			from = s.index + 1
+		}
+		p.updateSearch(from)
+	default:
+		p.stopSearch()
+		return false
+	}
+	return true
+}
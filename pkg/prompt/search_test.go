@@ -0,0 +1,119 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func newSearchPrompt(history []string) *Prompt {
+	p := New(
+		func(ctx context.Context, s string) {},
+		func(Document) []Suggest { return nil },
+		OptionWriter(NewIOWriter(&bytes.Buffer{})),
+		OptionHistory(history),
+	)
+	p.renderer.UpdateWinSize(&WinSize{Row: 24, Col: 80})
+	return p
+}
+
+func feedString(p *Prompt, s string) {
+	for _, r := range s {
+		p.feed([]byte(string(r)))
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	history := []string{
+		"get pods -n payments",
+		"get nodes",
+		"logs api-0 -n payments",
+		"get deploy",
+	}
+
+	t.Run("latest match", func(t *testing.T) {
+		p := newSearchPrompt(history)
+		p.feed([]byte{0x12})
+		feedString(p, "payments")
+		if got, want := p.buf.Text(), "logs api-0 -n payments"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+		if got, want := p.renderer.getCurrentPrefix(), "(reverse-i-search)`payments': "; got != want {
+			t.Errorf("prefix = %q, want %q", got, want)
+		}
+		if got, want := p.buf.Document().TextBeforeCursor(), "logs api-0 -n "; got != want {
+			t.Errorf("text before cursor = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("older match", func(t *testing.T) {
+		p := newSearchPrompt(history)
+		p.feed([]byte{0x12})
+		feedString(p, "payments")
+		p.feed([]byte{0x12})
+		if got, want := p.buf.Text(), "get pods -n payments"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+		// no older match keeps the last one
+		p.feed([]byte{0x12})
+		if got, want := p.buf.Text(), "get pods -n payments"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+		if got, want := p.renderer.getCurrentPrefix(), "(failed reverse-i-search)`payments': "; got != want {
+			t.Errorf("prefix = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("backspace", func(t *testing.T) {
+		p := newSearchPrompt(history)
+		p.feed([]byte{0x12})
+		feedString(p, "get p")
+		if got, want := p.buf.Text(), "get pods -n payments"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+		p.feed([]byte{0x7f})
+		if got, want := p.buf.Text(), "get deploy"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("enter executes match", func(t *testing.T) {
+		p := newSearchPrompt(history)
+		p.feed([]byte{0x12})
+		feedString(p, "nodes")
+		_, e := p.feed([]byte{0x0d})
+		if e == nil || e.input != "get nodes" {
+			t.Fatalf("exec = %+v, want get nodes", e)
+		}
+		if p.search != nil || p.renderer.search != nil {
+			t.Errorf("search not stopped")
+		}
+	})
+
+	for name, key := range map[string]byte{"escape": 0x1b, "ctrl-g": 0x07} {
+		t.Run(name+" restores buffer", func(t *testing.T) {
+			p := newSearchPrompt(history)
+			feedString(p, "get sv")
+			p.feed([]byte{0x12})
+			feedString(p, "logs")
+			p.feed([]byte{key})
+			if got, want := p.buf.Text(), "get sv"; got != want {
+				t.Errorf("buffer = %q, want %q", got, want)
+			}
+			if got, want := p.renderer.getCurrentPrefix(), "> "; got != want {
+				t.Errorf("prefix = %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("other keys stop searching", func(t *testing.T) {
+		p := newSearchPrompt(history)
+		p.feed([]byte{0x12})
+		feedString(p, "deploy")
+		p.feed([]byte{0x05}) // ctrl-e
+		feedString(p, " -o yaml")
+		if got, want := p.buf.Text(), "get deploy -o yaml"; got != want {
+			t.Errorf("buffer = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,478 @@
+package prompt
+
+import (
+	"unicode"
+)
+
+/*
+
+========
+PROGRESS
+========
+
+Insert mode
+-----------
+
+* [x] Esc        Switch to normal mode
+* [x] Ctrl + h   Delete character before the cursor
+* [x] Ctrl + w   Delete the word before the cursor
+* [x] Ctrl + u   Delete the line before the cursor
+
+Normal mode
+-----------
+
+* [x] i a I A    Switch to insert mode
+* [x] h l        Backward/forward one character
+* [x] w b e      Forward to the next word, backward/forward to its start/end
+* [x] 0 ^ $      Go to the beginning, first non-blank, end of the line
+* [x] f t F T    Forward/backward to (before) the next character typed
+* [x] j k        Next/previous command
+* [x] d c y      Delete, change, yank with a motion, or the line if doubled
+* [x] x X D C s S
+* [x] p P        Put the last deleted or yanked text after/before the cursor
+* [x] .          Repeat the last change
+* [x] u          Undo
+* [ ] 2w 3dd     Counts
+
+*/
+
+// viState is the state of the vi key bindings.
+type viState struct {
+	normal bool
+	// pending are the keys of an incomplete command in normal mode, e.g. d
+	// waiting for its motion.
+	pending []rune
+	// register holds the text last deleted or yanked.
+	register string
+	// last is the last change, repeated by '.', recording is the change
+	// whose insert mode has not ended yet.
+	last      *viChange
+	recording *viChange
+	replaying bool
+}
+
+// viChange is a command changing the buffer and, if it switched to insert
+// mode, the keys typed until insert mode ended.
+type viChange struct {
+	command  string
+	inserted []KeyEvent
+}
+
+// viResult is the result of feeding the keys of a normal mode command.
+type viResult int
+
+const (
+	viDone viResult = iota
+	viIncomplete
+	viFailed
+)
+
+// indicator returns the mode indicator rendered before the prefix.
+func (v *viState) indicator() string {
+	switch {
+	case v == nil:
+		return ""
+	case v.normal:
+		return "(cmd) "
+	default:
+		return "(ins) "
+	}
+}
+
+// reset switches to insert mode for the next input.
+func (v *viState) reset() {
+	if v == nil {
+		return
+	}
+	v.normal = false
+	v.pending = nil
+	v.recording = nil
+}
+
+var viInsertKeyBindings = []KeyBind{
+	// Backspace
+	{
+		Key: ControlH,
+		Fn:  DeleteBeforeChar,
+	},
+	// Delete the word before the cursor
+	{
+		Key: ControlW,
+		Fn: func(buf *Buffer) {
+			buf.DeleteBeforeCursor(len([]rune(buf.Document().GetWordBeforeCursorWithSpace())))
+		},
+	},
+	// Delete the line before the cursor
+	{
+		Key: ControlU,
+		Fn: func(buf *Buffer) {
+			buf.DeleteBeforeCursor(len([]rune(buf.Document().TextBeforeCursor())))
+		},
+	},
+}
+
+// feedVi handles a key with the vi key bindings. It returns true if the key
+// was handled, otherwise it is handled as usual.
+func (p *Prompt) feedVi(ev KeyEvent) bool {
+	v := p.vi
+	// Esc followed quickly by a key is decoded as the key pressed with Alt
+	if ev.Key == NotDefined && ev.Mod == ModAlt && len(ev.Data) > 1 && ev.Data[0] == 0x1b {
+		p.feedVi(KeyEvent{Key: Escape, Data: ev.Data[:1]})
+		return p.feedVi(KeyEvent{Key: NotDefined, Data: ev.Data[1:]})
+	}
+
+	if !v.normal {
+		if ev.Key == Escape {
+			p.viEscape()
+			return true
+		}
+		if v.recording != nil {
+			ev.Data = append([]byte(nil), ev.Data...)
+			v.recording.inserted = append(v.recording.inserted, ev)
+		}
+		return false
+	}
+
+	switch {
+	case ev.Key == Escape:
+		v.pending = nil
+		return true
+	case ev.Key != NotDefined || ev.Mod != 0 || ev.Data[0] == 0x1b:
+		v.pending = nil
+		return false
+	}
+
+	keys := []rune(string(ev.Data))
+	for i, r := range keys {
+		if !v.normal {
+			// a command switched to insert mode, the rest is typed text
+			text := string(keys[i:])
+			if v.recording != nil {
+				v.recording.inserted = append(v.recording.inserted, KeyEvent{Key: NotDefined, Data: []byte(text)})
+			}
+			p.buf.InsertText(text, false, true)
+			return true
+		}
+		if len(v.pending) == 0 && r == '.' {
+			p.viRepeat()
+			continue
+		}
+
+		v.pending = append(v.pending, r)
+		res, change := p.viCommand(v.pending)
+		if res == viIncomplete {
+			continue
+		}
+		if res == viDone && change && !v.replaying {
+			c := &viChange{command: string(v.pending)}
+			if v.normal {
+				v.last = c
+			} else {
+				v.recording = c
+			}
+		}
+		v.pending = nil
+		p.viClampCursor()
+	}
+	return true
+}
+
+// viEscape ends insert mode, the cursor moves onto the last character typed.
+func (p *Prompt) viEscape() {
+	v := p.vi
+	v.normal = true
+	p.buf.CursorLeft(1)
+	if v.recording != nil {
+		v.last, v.recording = v.recording, nil
+	}
+}
+
+// viRepeat repeats the last change.
+func (p *Prompt) viRepeat() {
+	v := p.vi
+	if v.last == nil {
+		return
+	}
+	v.replaying = true
+	defer func() { v.replaying = false }()
+
+	if res, _ := p.viCommand([]rune(v.last.command)); res != viDone {
+		return
+	}
+	for _, ev := range v.last.inserted {
+		p.feedKey(ev)
+	}
+	if !v.normal {
+		p.viEscape()
+	}
+	p.viClampCursor()
+}
+
+// viClampCursor keeps the cursor on a character in normal mode.
+func (p *Prompt) viClampCursor() {
+	if !p.vi.normal {
+		return
+	}
+	if n := len([]rune(p.buf.Text())); p.buf.cursorPosition >= n && n > 0 {
+		p.buf.cursorPosition = n - 1
+	}
+}
+
+// viCommand runs the normal mode command in keys. change is true if the
+// command changes the buffer and can be repeated.
+func (p *Prompt) viCommand(keys []rune) (res viResult, change bool) {
+	v := p.vi
+	text := []rune(p.buf.Text())
+	c := p.buf.cursorPosition
+
+	switch k := keys[0]; k {
+	case 'i':
+		v.normal = false
+		return viDone, true
+	case 'a':
+		if len(text) > 0 {
+			p.buf.cursorPosition = c + 1
+		}
+		v.normal = false
+		return viDone, true
+	case 'I':
+		p.buf.cursorPosition = 0
+		v.normal = false
+		return viDone, true
+	case 'A':
+		p.buf.cursorPosition = len(text)
+		v.normal = false
+		return viDone, true
+	case 'x':
+		return p.viOperate('d', []rune{'l'}), true
+	case 'X':
+		return p.viOperate('d', []rune{'h'}), true
+	case 'D':
+		return p.viOperate('d', []rune{'$'}), true
+	case 'C':
+		return p.viOperate('c', []rune{'$'}), true
+	case 's':
+		return p.viOperate('c', []rune{'l'}), true
+	case 'S':
+		return p.viOperate('c', []rune{'c'}), true
+	case 'd', 'c', 'y':
+		if len(keys) < 2 {
+			return viIncomplete, false
+		}
+		return p.viOperate(k, keys[1:]), k != 'y'
+	case 'p', 'P':
+		if v.register == "" {
+			return viFailed, false
+		}
+		if k == 'p' && len(text) > 0 {
+			c++
+		}
+		p.buf.cursorPosition = c
+		p.buf.InsertText(v.register, false, false)
+		p.buf.cursorPosition = c + len([]rune(v.register)) - 1
+		return viDone, true
+	case 'u':
+		if !p.buf.Undo() {
+			return viFailed, false
+		}
+		return viDone, false
+	case 'k':
+		if buf, changed := p.history.Older(p.buf); changed {
+			p.buf = buf
+			p.buf.cursorPosition = 0
+		}
+		return viDone, false
+	case 'j':
+		if buf, changed := p.history.Newer(p.buf); changed {
+			p.buf = buf
+			p.buf.cursorPosition = 0
+		}
+		return viDone, false
+	}
+
+	pos, _, res := viMotion(text, c, keys, 0)
+	if res == viDone {
+		p.buf.cursorPosition = min(max(pos, 0), len(text))
+	}
+	return res, false
+}
+
+// viOperate deletes, changes or yanks the text from the cursor to where
+// motion moves it, or the whole line if the operator is doubled.
+func (p *Prompt) viOperate(op rune, motion []rune) viResult {
+	v := p.vi
+	text := []rune(p.buf.Text())
+	c := p.buf.cursorPosition
+
+	start, end := 0, len(text)
+	if motion[0] != op {
+		pos, inclusive, res := viMotion(text, c, motion, op)
+		if res != viDone {
+			return res
+		}
+		start, end = c, pos
+		if pos < c {
+			start, end = pos, c
+		}
+		if inclusive {
+			end++
+		}
+		// motions stay in the text, but the text is sliced by them
+		start, end = min(max(start, 0), len(text)), min(max(end, 0), len(text))
+	}
+
+	if start == end && op != 'c' {
+		return viFailed
+	}
+	v.register = string(text[start:end])
+	if op == 'y' {
+		p.buf.cursorPosition = start
+		return viDone
+	}
+	p.buf.saveUndo(false)
+	p.buf.setDocument(&Document{
+		Text:           string(text[:start]) + string(text[end:]),
+		cursorPosition: start,
+	})
+	if op == 'c' {
+		v.normal = false
+	}
+	return viDone
+}
+
+// viMotion returns the position the motion in keys moves the cursor c to.
+// inclusive is true if an operator includes the character at pos, op is
+// the operator the motion is for or 0.
+func viMotion(text []rune, c int, keys []rune, op rune) (pos int, inclusive bool, res viResult) {
+	n := len(text)
+	switch k := keys[0]; k {
+	case 'h':
+		if c == 0 {
+			return c, false, viFailed
+		}
+		return c - 1, false, viDone
+	case 'l':
+		if c >= n {
+			return c, false, viFailed
+		}
+		return c + 1, false, viDone
+	case '0':
+		return 0, false, viDone
+	case '^':
+		pos = 0
+		for pos < n && unicode.IsSpace(text[pos]) {
+			pos++
+		}
+		return pos, false, viDone
+	case '$':
+		if n == 0 {
+			return 0, false, viDone
+		}
+		return n - 1, true, viDone
+	case 'w':
+		// like vim, cw on a word changes to its end
+		if op == 'c' && c < n && !unicode.IsSpace(text[c]) {
+			return viWordEnd(text, c, false), true, viDone
+		}
+		return viNextWord(text, c), false, viDone
+	case 'e':
+		return viWordEnd(text, c, true), true, viDone
+	case 'b':
+		return viPreviousWord(text, c), false, viDone
+	case 'f', 't', 'F', 'T':
+		if len(keys) < 2 {
+			return c, false, viIncomplete
+		}
+		return viFind(text, c, k, keys[1])
+	}
+	return c, false, viFailed
+}
+
+// viClass returns the class of a character, a word is a run of characters
+// of the same class other than blanks.
+func viClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// viNextWord returns the start of the word after c.
+func viNextWord(text []rune, c int) int {
+	n := len(text)
+	if c >= n {
+		return n
+	}
+	pos := c
+	for class := viClass(text[c]); pos < n && class != 0 && viClass(text[pos]) == class; pos++ {
+	}
+	for pos < n && unicode.IsSpace(text[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// viWordEnd returns the end of the word at c, or of the next word if c is
+// at the end of a word already and next is true.
+func viWordEnd(text []rune, c int, next bool) int {
+	n := len(text)
+	pos := c
+	if next {
+		pos++
+	}
+	for pos < n && unicode.IsSpace(text[pos]) {
+		pos++
+	}
+	if pos >= n {
+		return max(n-1, 0)
+	}
+	for class := viClass(text[pos]); pos+1 < n && viClass(text[pos+1]) == class; pos++ {
+	}
+	return pos
+}
+
+// viPreviousWord returns the start of the word before c.
+func viPreviousWord(text []rune, c int) int {
+	pos := c - 1
+	for pos > 0 && unicode.IsSpace(text[pos]) {
+		pos--
+	}
+	if pos <= 0 {
+		return 0
+	}
+	for class := viClass(text[pos]); pos > 0 && viClass(text[pos-1]) == class; pos-- {
+	}
+	return pos
+}
+
+// viFind returns the position of the next character r after c for f, or
+// before it for t. F and T search backward.
+func viFind(text []rune, c int, k, r rune) (int, bool, viResult) {
+	switch k {
+	case 'f', 't':
+		for pos := c + 1; pos < len(text); pos++ {
+			if text[pos] != r {
+				continue
+			}
+			if k == 't' {
+				pos--
+			}
+			return pos, true, viDone
+		}
+	case 'F', 'T':
+		for pos := c - 1; pos >= 0; pos-- {
+			if text[pos] != r {
+				continue
+			}
+			if k == 'T' {
+				pos++
+			}
+			return pos, false, viDone
+		}
+	}
+	return c, false, viFailed
+}
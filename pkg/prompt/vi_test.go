@@ -0,0 +1,194 @@
+package prompt
+
+import "testing"
+
+func TestViKeyBindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   string
+		want   string
+		cursor int
+		normal bool
+		// chunk is true if the keys are typed at once, otherwise one by one
+		chunk bool
+		// empty is true if the keys are typed in an empty buffer
+		empty bool
+	}{
+		{
+			name:   "delete word",
+			keys:   "\x1b0dw",
+			want:   "pods -n kube-system",
+			cursor: 0,
+			normal: true,
+		},
+		{
+			name:   "change word",
+			keys:   "\x1b0cwlist\x1b",
+			want:   "list pods -n kube-system",
+			cursor: 3,
+			normal: true,
+		},
+		{
+			name:   "delete to character",
+			keys:   "\x1b0wwdfn",
+			want:   "get pods  kube-system",
+			cursor: 9,
+			normal: true,
+		},
+		{
+			name:   "delete before character",
+			keys:   "\x1b0dt ",
+			want:   " pods -n kube-system",
+			cursor: 0,
+			normal: true,
+		},
+		{
+			name:   "delete to word end",
+			keys:   "\x1b$bde",
+			want:   "get pods -n kube-",
+			cursor: 16,
+			normal: true,
+		},
+		{
+			name:   "delete backward to character",
+			keys:   "\x1bdFk",
+			want:   "get pods -n m",
+			cursor: 12,
+			normal: true,
+		},
+		{
+			name:   "yank and put",
+			keys:   "\x1b0yep",
+			want:   "ggetet pods -n kube-system",
+			cursor: 3,
+			normal: true,
+		},
+		{
+			name:   "repeat delete",
+			keys:   "\x1b0x..",
+			want:   " pods -n kube-system",
+			cursor: 0,
+			normal: true,
+		},
+		{
+			name:   "repeat change",
+			keys:   "\x1b0cwlist\x1bw.",
+			want:   "list list -n kube-system",
+			cursor: 8,
+			normal: true,
+		},
+		{
+			name:   "change line",
+			keys:   "\x1bccget nodes",
+			want:   "get nodes",
+			cursor: 9,
+		},
+		{
+			name:   "delete line and append",
+			keys:   "\x1bddAlogs",
+			want:   "logs",
+			cursor: 4,
+		},
+		{
+			name:   "insert at beginning",
+			keys:   "\x1bIkubectl ",
+			want:   "kubectl get pods -n kube-system",
+			cursor: 8,
+		},
+		{
+			name:   "escape and keys in one chunk",
+			keys:   "\x1b0dw",
+			want:   "pods -n kube-system",
+			cursor: 0,
+			normal: true,
+			chunk:  true,
+		},
+		{
+			name:   "word end in empty buffer",
+			empty:  true,
+			keys:   "\x1be",
+			normal: true,
+		},
+		{
+			name:   "delete to word end in empty buffer",
+			empty:  true,
+			keys:   "\x1bde",
+			normal: true,
+		},
+		{
+			name:   "change to word end in empty buffer",
+			empty:  true,
+			keys:   "\x1bcex",
+			want:   "x",
+			cursor: 1,
+		},
+		{
+			name:   "previous word in empty buffer",
+			empty:  true,
+			keys:   "\x1bb",
+			normal: true,
+		},
+		{
+			name:   "next word in empty buffer",
+			empty:  true,
+			keys:   "\x1bw",
+			normal: true,
+		},
+		{
+			name:   "unknown command",
+			keys:   "\x1bzq",
+			want:   "get pods -n kube-system",
+			cursor: 22,
+			normal: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newPastePrompt(OptionSwitchKeyBindMode(ViKeyBind))
+			if !tt.empty {
+				p.feed([]byte("get pods -n kube-system"))
+			}
+			if tt.chunk {
+				p.feed([]byte(tt.keys))
+			} else {
+				feedString(p, tt.keys)
+			}
+			if got := p.buf.Text(); got != tt.want {
+				t.Errorf("buffer = %q, want %q", got, tt.want)
+			}
+			if got := p.buf.cursorPosition; got != tt.cursor {
+				t.Errorf("cursor = %d, want %d", got, tt.cursor)
+			}
+			if p.vi.normal != tt.normal {
+				t.Errorf("normal = %t, want %t", p.vi.normal, tt.normal)
+			}
+		})
+	}
+}
+
+func TestViModeIndicator(t *testing.T) {
+	p, executed := newPastePrompt(OptionSwitchKeyBindMode(ViKeyBind))
+	if got, want := p.renderer.getCurrentPrefix(), "(ins) > "; got != want {
+		t.Errorf("prefix = %q, want %q", got, want)
+	}
+	p.feed([]byte("get pods\x1b"))
+	if got, want := p.renderer.getCurrentPrefix(), "(cmd) > "; got != want {
+		t.Errorf("prefix = %q, want %q", got, want)
+	}
+
+	// entering the input starts the next one in insert mode
+	if _, e := p.feed([]byte("\r")); e == nil || e.input != "get pods" {
+		t.Fatalf("executed %+v, want %q", e, "get pods")
+	}
+	if got, want := p.renderer.getCurrentPrefix(), "(ins) > "; got != want {
+		t.Errorf("prefix = %q, want %q", got, want)
+	}
+	if len(*executed) != 0 {
+		t.Errorf("executor called with %q", *executed)
+	}
+
+	p.feed([]byte("\x1bk"))
+	if got, want := p.buf.Text(), "get pods"; got != want {
+		t.Errorf("buffer = %q, want %q", got, want)
+	}
+}